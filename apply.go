@@ -0,0 +1,293 @@
+package debugtools
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Apply changes the value target points to, which should be equal to the
+// left-hand side of d, so that it matches the right-hand side: modified
+// values are replaced, inserted slice elements and map entries are added
+// and deleted ones are removed. It can be used to regenerate golden values
+// from a recorded Diff.
+//
+// Values are copied from d shallowly, so target may end up sharing maps,
+// slices and pointers with the right-hand value. Apply returns an error if
+// a change can't be made, such as a change to an unexported field, a map
+// key whose type can't be parsed back from its path, or a value whose type
+// doesn't fit; changes made before the error are kept.
+func Apply(d *DiffTree, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errors.New("debugtools: Apply target must be a non-nil pointer")
+	}
+	if d.Root == nil {
+		return nil
+	}
+	if d.Root.IsLeaf() {
+		return applyLeaf(v.Elem(), d.Root)
+	}
+	return applyNode(v.Elem(), d.Root)
+}
+
+// applyNode applies the changes beneath n to v, the value at n's path.
+func applyNode(v reflect.Value, n *Difference) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return fmt.Errorf("debugtools: can't apply changes to %s: nil pointer", displayPath(n.Path))
+		}
+		return applyNode(v.Elem(), n)
+	case reflect.Interface:
+		if v.IsNil() {
+			return fmt.Errorf("debugtools: can't apply changes to %s: nil interface", displayPath(n.Path))
+		}
+		// The dynamic value isn't settable, so change a copy.
+		tmp := reflect.New(v.Elem().Type()).Elem()
+		tmp.Set(v.Elem())
+		if err := applyNode(tmp, n); err != nil {
+			return err
+		}
+		return setValue(v, tmp, n.Path)
+	case reflect.Struct:
+		for _, c := range n.Children {
+			name := strings.TrimPrefix(c.Path, n.Path+".")
+			f := v.FieldByName(name)
+			if !f.IsValid() {
+				return fmt.Errorf("debugtools: can't apply change to %s: no field %s in %s", c.Path, name, v.Type())
+			}
+			if err := applyChild(f, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Array:
+		for _, c := range n.Children {
+			i, err := indexStep(n, c, v.Len())
+			if err != nil {
+				return err
+			}
+			if err := applyChild(v.Index(i), c); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice:
+		return applySlice(v, n)
+	case reflect.Map:
+		return applyMap(v, n)
+	}
+	return fmt.Errorf("debugtools: can't apply changes beneath %s to %s", displayPath(n.Path), v.Type())
+}
+
+// applyChild applies c, a change within a struct or array, to v.
+func applyChild(v reflect.Value, c *Difference) error {
+	if !c.IsLeaf() {
+		return applyNode(v, c)
+	}
+	if c.Kind == Deleted {
+		// A pointer or interface that became nil.
+		return setValue(v, reflect.Zero(v.Type()), c.Path)
+	}
+	return applyLeaf(v, c)
+}
+
+// applyLeaf replaces v with the right-hand value of c.
+func applyLeaf(v reflect.Value, c *Difference) error {
+	rv, err := rightValue(c, v.Type())
+	if err != nil {
+		return err
+	}
+	return setValue(v, rv, c.Path)
+}
+
+// applySlice applies the changes beneath n to the slice v. Modified and
+// deleted elements are indexed as on the left, and inserted ones as on the
+// right, so modifications are made in place first, then deletions removed,
+// then insertions made in increasing order.
+func applySlice(v reflect.Value, n *Difference) error {
+	deleted := make(map[int]bool)
+	inserted := make(map[int]*Difference)
+	for _, c := range n.Children {
+		i, err := indexStep(n, c, -1)
+		if err != nil {
+			return err
+		}
+		switch {
+		case c.IsLeaf() && c.Kind == Inserted:
+			inserted[i] = c
+			continue
+		case i >= v.Len():
+			return fmt.Errorf("debugtools: can't apply change to %s: index out of range", c.Path)
+		case !c.IsLeaf():
+			err = applyNode(v.Index(i), c)
+		case c.Kind == Deleted:
+			deleted[i] = true
+		default:
+			err = applyLeaf(v.Index(i), c)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(deleted) == 0 && len(inserted) == 0 {
+		return nil
+	}
+	elems := make([]reflect.Value, 0, v.Len()-len(deleted)+len(inserted))
+	for i := 0; i < v.Len(); i++ {
+		if !deleted[i] {
+			elems = append(elems, v.Index(i))
+		}
+	}
+	indexes := make([]int, 0, len(inserted))
+	for j := range inserted {
+		indexes = append(indexes, j)
+	}
+	sort.Ints(indexes)
+	for _, j := range indexes {
+		c := inserted[j]
+		if j > len(elems) {
+			return fmt.Errorf("debugtools: can't apply change to %s: index out of range", c.Path)
+		}
+		ev, err := rightValue(c, v.Type().Elem())
+		if err != nil {
+			return err
+		}
+		elems = append(elems[:j], append([]reflect.Value{ev}, elems[j:]...)...)
+	}
+	s := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+	for i, e := range elems {
+		s.Index(i).Set(e)
+	}
+	return setValue(v, s, n.Path)
+}
+
+// applyMap applies the changes beneath n to the map v.
+func applyMap(v reflect.Value, n *Difference) error {
+	if v.IsNil() {
+		if err := setValue(v, reflect.MakeMap(v.Type()), n.Path); err != nil {
+			return err
+		}
+	}
+	keys := make(map[string]reflect.Value, v.Len())
+	for _, k := range v.MapKeys() {
+		keys["["+anyString(k)+"]"] = k
+	}
+	for _, c := range n.Children {
+		step := strings.TrimPrefix(c.Path, n.Path)
+		k, ok := keys[step]
+		if !ok {
+			var err error
+			if k, err = parseMapKey(step, v.Type().Key()); err != nil {
+				return fmt.Errorf("debugtools: can't apply change to %s: %w", c.Path, err)
+			}
+		}
+		switch {
+		case !c.IsLeaf():
+			// Map elements aren't settable, so change a copy.
+			tmp := reflect.New(v.Type().Elem()).Elem()
+			tmp.Set(v.MapIndex(k))
+			if err := applyNode(tmp, c); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, tmp)
+		case c.Kind == Deleted:
+			v.SetMapIndex(k, reflect.Value{})
+		default:
+			ev, err := rightValue(c, v.Type().Elem())
+			if err != nil {
+				return err
+			}
+			v.SetMapIndex(k, ev)
+		}
+	}
+	return nil
+}
+
+// indexStep returns the index in c's path beneath n, checking it against
+// max if that isn't negative.
+func indexStep(n, c *Difference, max int) (int, error) {
+	step := strings.TrimPrefix(c.Path, n.Path)
+	i, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(step, "["), "]"))
+	if err != nil || i < 0 || max >= 0 && i >= max {
+		return 0, fmt.Errorf("debugtools: can't apply change to %s: bad index %s", c.Path, step)
+	}
+	return i, nil
+}
+
+// parseMapKey parses a map key of type t from its path step, which holds
+// the key formatted with %#v. Only keys of basic kinds can be parsed.
+func parseMapKey(step string, t reflect.Type) (reflect.Value, error) {
+	s := strings.TrimSuffix(strings.TrimPrefix(step, "["), "]")
+	k := reflect.New(t).Elem()
+	var err error
+	switch t.Kind() {
+	case reflect.String:
+		var x string
+		if x, err = strconv.Unquote(s); err == nil {
+			k.SetString(x)
+		}
+	case reflect.Bool:
+		var x bool
+		if x, err = strconv.ParseBool(s); err == nil {
+			k.SetBool(x)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var x int64
+		if x, err = strconv.ParseInt(s, 0, t.Bits()); err == nil {
+			k.SetInt(x)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var x uint64
+		if x, err = strconv.ParseUint(s, 0, t.Bits()); err == nil {
+			k.SetUint(x)
+		}
+	case reflect.Float32, reflect.Float64:
+		var x float64
+		if x, err = strconv.ParseFloat(s, t.Bits()); err == nil {
+			k.SetFloat(x)
+		}
+	default:
+		return reflect.Value{}, fmt.Errorf("can't parse map key of type %s", t)
+	}
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("can't parse map key %s: %w", s, err)
+	}
+	return k, nil
+}
+
+// rightValue returns the right-hand value of c as a value of type t.
+func rightValue(c *Difference, t reflect.Type) (reflect.Value, error) {
+	if c.Right == nil {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+			if c.RightText != "" {
+				return reflect.Zero(t), nil
+			}
+		}
+		return reflect.Value{}, fmt.Errorf("debugtools: can't apply change to %s: right-hand value not available", displayPath(c.Path))
+	}
+	rv := reflect.ValueOf(c.Right)
+	if t.Kind() == reflect.Ptr && rv.Type().AssignableTo(t.Elem()) {
+		// Pointers are compared, and so reported, by what they point to.
+		p := reflect.New(t.Elem())
+		p.Elem().Set(rv)
+		return p, nil
+	}
+	if !rv.Type().AssignableTo(t) {
+		return reflect.Value{}, fmt.Errorf("debugtools: can't apply change to %s: %s is not assignable to %s", displayPath(c.Path), rv.Type(), t)
+	}
+	return rv, nil
+}
+
+func setValue(v, x reflect.Value, path string) error {
+	if !v.CanSet() {
+		return fmt.Errorf("debugtools: can't apply change to %s: value can't be set", displayPath(path))
+	}
+	v.Set(x)
+	return nil
+}
@@ -0,0 +1,104 @@
+package debugtools
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// artifactsEnv names the directory failure artifacts are written under.
+const artifactsEnv = "TEST_ARTIFACTS"
+
+// WriteFailureArtifacts makes the assertion helpers, such as Config.Assert
+// and Eventually, write the full dumps of the values they compared and
+// their differences, as a JSON DiffTree, to files when an assertion fails,
+// and list the files in the failure message, so that a failure in CI can
+// be examined without running the test again. The files go in a new
+// directory under $TEST_ARTIFACTS/<test name> if TEST_ARTIFACTS is set,
+// and otherwise under t.TempDir(), which is removed when the test ends, so
+// CI jobs should set TEST_ARTIFACTS to a directory they keep.
+func WriteFailureArtifacts() Option {
+	return func(o *options) {
+		o.failureArtifacts = true
+	}
+}
+
+// An artifact is a file written by WriteFailureArtifacts.
+type artifact struct {
+	name string
+	data []byte
+}
+
+// artifactsNote writes the artifacts made by files, if WriteFailureArtifacts
+// is among opts, and returns the lines listing them to add to the failure
+// message of t.
+func artifactsNote(t testing.TB, opts []Option, files func() []artifact) string {
+	if !newOptions(opts).failureArtifacts {
+		return ""
+	}
+	base := os.Getenv(artifactsEnv)
+	if base == "" {
+		base = t.TempDir()
+	} else {
+		base = filepath.Join(base, artifactDirName(t.Name()))
+	}
+	if err := os.MkdirAll(base, 0o755); err != nil {
+		return "\nfailure artifacts not written: " + err.Error()
+	}
+	dir, err := os.MkdirTemp(base, "failure-")
+	if err != nil {
+		return "\nfailure artifacts not written: " + err.Error()
+	}
+	var sb strings.Builder
+	sb.WriteString("\nfailure artifacts:")
+	for _, a := range files() {
+		path := filepath.Join(dir, a.name)
+		if err := os.WriteFile(path, a.data, 0o644); err != nil {
+			sb.WriteString("\n\t" + err.Error())
+			continue
+		}
+		sb.WriteString("\n\t" + path)
+	}
+	return sb.String()
+}
+
+// valueArtifacts returns the dumps of want and got, and their differences
+// as compared with opts.
+func valueArtifacts(want, got interface{}, opts []Option) []artifact {
+	dump := func(v interface{}) []byte {
+		return []byte(Sdump(v, DumpColor(ColorNever), DumpUnexported()))
+	}
+	return []artifact{
+		{"want.txt", dump(want)},
+		{"got.txt", dump(got)},
+		diffArtifact(Diff(want, got, opts...)),
+	}
+}
+
+// diffArtifact returns the DiffTree d as JSON, or as text if its values
+// can't be marshaled.
+func diffArtifact(d *DiffTree, err error) artifact {
+	if err != nil {
+		return artifact{"diff.txt", []byte(err.Error() + "\n")}
+	}
+	if b, err := json.MarshalIndent(d, "", "  "); err == nil {
+		return artifact{"diff.json", append(b, '\n')}
+	}
+	return artifact{"diff.txt", []byte(d.String())}
+}
+
+// artifactDirName turns a test name into a relative directory path,
+// keeping the slashes between subtests and replacing characters that are
+// awkward in file names.
+func artifactDirName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '_', r == '-', r == '.', r == '/':
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,164 @@
+package debugtools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Eventually calls get every interval until the value it returns is equal
+// to want, as DeepEqual decides with opts, and reports whether it became
+// equal before timeout. If it didn't, t fails with the differences between
+// want and the last value get returned, rather than a bare "condition not
+// met", which shows how close the code under test came:
+//
+//	debugtools.Eventually(t, time.Second, 10*time.Millisecond,
+//		func() interface{} { return cache.Stats() }, wantStats)
+//
+// get is always called at least once. interval must be positive; t fails
+// immediately, via Fatalf, if it isn't.
+func Eventually(t testing.TB, timeout, interval time.Duration, get func() (got interface{}), want interface{}, opts ...Option) bool {
+	t.Helper()
+	if interval <= 0 {
+		t.Fatalf("Eventually: interval must be positive, got %v", interval)
+		return false
+	}
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for attempts := 1; ; attempts++ {
+		got := get()
+		if eq, _ := DeepEqual(want, got, opts...); eq {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			fail(t, opts, fmt.Sprintf("value not equal after %v (%d attempts); last value differs from want:\n%s%s",
+				timeout, attempts, describeDiff(want, got, opts), artifactsNote(t, opts, func() []artifact {
+					return valueArtifacts(want, got, opts)
+				})))
+			return false
+		}
+		<-ticker.C
+	}
+}
+
+// AssertJSONEqual checks that the JSON documents got and want are equal as
+// DiffJSON compares them, so that whitespace, key order and the spelling
+// of numbers don't matter, and reports whether they are. If they aren't, t
+// fails with their differences, addressed by their paths in the document.
+// IgnorePaths and OnlyPaths can be used to leave out parts of the
+// documents, such as generated IDs:
+//
+//	debugtools.AssertJSONEqual(t, rec.Body.Bytes(), []byte(`{"name": "pen"}`),
+//		debugtools.IgnorePaths("id"))
+func AssertJSONEqual(t testing.TB, got, want []byte, opts ...Option) bool {
+	t.Helper()
+	for _, doc := range []struct {
+		name string
+		data []byte
+	}{{"got", got}, {"want", want}} {
+		if _, err := decodeJSON(doc.data); err != nil {
+			t.Errorf("%s is not valid JSON: %v", doc.name, err)
+			return false
+		}
+	}
+	d, err := DiffJSON(want, got, opts...)
+	if err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+	if d.Equal() {
+		return true
+	}
+	fail(t, opts, fmt.Sprintf("JSON differs from want:\n%s%s", d, artifactsNote(t, opts, func() []artifact {
+		return []artifact{{"want.json", want}, {"got.json", got}, diffArtifact(d, nil)}
+	})))
+	return false
+}
+
+// An Attempt is passed to each call of a step being retried by Retry, to
+// keep the values that explain its outcome.
+type Attempt struct {
+	// N is the number of the attempt, starting at 1.
+	N      int
+	names  []string
+	values []interface{}
+}
+
+// Keep records v under name, to be dumped if this turns out to be the last
+// attempt and it fails. Keeping a name again replaces its value.
+func (a *Attempt) Keep(name string, v interface{}) {
+	for i, n := range a.names {
+		if n == name {
+			a.values[i] = v
+			return
+		}
+	}
+	a.names = append(a.names, name)
+	a.values = append(a.values, v)
+}
+
+// Retry calls step up to attempts times, waiting interval between calls,
+// until it returns nil, and reports whether it did, for steps of
+// integration tests that fail now and then for reasons outside the test.
+// If the last attempt fails too, t fails with its error and the dump of
+// every value it kept, made with Sdump and opts, so that the request,
+// response or intermediate state that led to the failure can be examined
+// after the fact:
+//
+//	debugtools.Retry(t, 3, time.Second, func(a *debugtools.Attempt) error {
+//		req := newRequest()
+//		a.Keep("request", req)
+//		resp, err := client.Do(req)
+//		a.Keep("response", resp)
+//		return err
+//	})
+func Retry(t testing.TB, attempts int, interval time.Duration, step func(a *Attempt) error, opts ...Option) bool {
+	t.Helper()
+	opts = append(opts[:len(opts):len(opts)], DumpColor(ColorNever))
+	for n := 1; ; n++ {
+		a := &Attempt{N: n}
+		err := step(a)
+		if err == nil {
+			return true
+		}
+		if n >= attempts {
+			var sb strings.Builder
+			fmt.Fprintf(&sb, "failed after %d attempt(s): %v", n, err)
+			for i, name := range a.names {
+				fmt.Fprintf(&sb, "\n\n%s = %s", name, strings.TrimSuffix(Sdump(a.values[i], opts...), "\n"))
+			}
+			sb.WriteString(artifactsNote(t, opts, func() []artifact {
+				files := make([]artifact, len(a.names))
+				for i, name := range a.names {
+					files[i] = artifact{artifactDirName(name) + ".txt", []byte(Sdump(a.values[i], DumpColor(ColorNever), DumpUnexported()))}
+				}
+				return files
+			}))
+			fail(t, opts, sb.String())
+			return false
+		}
+		time.Sleep(interval)
+	}
+}
+
+// fail fails t with msg, first showing it in a pager if PageFailures calls
+// for it.
+func fail(t testing.TB, opts []Option, msg string) {
+	t.Helper()
+	if newOptions(opts).pageFailures {
+		page(msg)
+	}
+	t.Errorf("%s", msg)
+}
+
+// describeDiff lists the differences between want and got, for failure
+// messages.
+func describeDiff(want, got interface{}, opts []Option) string {
+	d, err := Diff(want, got, opts...)
+	if err != nil {
+		return fmt.Sprintf("types don't match: want %T, got %T\n", want, got)
+	}
+	return d.String()
+}
@@ -0,0 +1,205 @@
+package debugtools
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// benchUnits are the metrics DiffBenchmarks compares, all of which are
+// better when lower.
+var benchUnits = []string{"ns/op", "B/op", "allocs/op"}
+
+// defaultBenchThreshold is the relative change in a metric below which
+// DiffBenchmarks considers it noise.
+const defaultBenchThreshold = 0.05
+
+// BenchmarkThreshold sets the relative change, such as 0.1 for 10%, that a
+// metric must exceed for DiffBenchmarks to report it. unit is "ns/op",
+// "B/op" or "allocs/op", or "" to set the threshold for all three. The
+// default is 5%.
+func BenchmarkThreshold(unit string, fraction float64) Option {
+	return func(o *options) {
+		if o.benchThresholds == nil {
+			o.benchThresholds = make(map[string]float64)
+		}
+		if unit == "" {
+			for _, u := range benchUnits {
+				o.benchThresholds[u] = fraction
+			}
+		} else {
+			o.benchThresholds[unit] = fraction
+		}
+	}
+}
+
+// DiffBenchmarks parses old and new, two outputs of go test -bench, and
+// compares the ns/op, B/op and allocs/op of each benchmark, as a lightweight
+// benchstat. The result holds a node for each benchmark that changed, with
+// a Modified leaf for each metric that changed by more than its threshold,
+// such as
+//
+//	~ ["BenchmarkParse-8"]["ns/op"]: 1200 → 1500 (+25.0%), regression
+//
+// set by BenchmarkThreshold, and an Inserted or Deleted leaf for a
+// benchmark only run on one side. Leaves hold the metrics as float64
+// values. A benchmark run several times, with -count, is represented by
+// the median of its runs. Lines other than benchmark results are ignored,
+// but it is an error for either output to contain none.
+func DiffBenchmarks(old, new []byte, opts ...Option) (*DiffTree, error) {
+	o := newOptions(opts)
+	r1, err := parseBenchmarks(old)
+	if err != nil {
+		return nil, fmt.Errorf("debugtools: parsing old benchmarks: %w", err)
+	}
+	r2, err := parseBenchmarks(new)
+	if err != nil {
+		return nil, fmt.Errorf("debugtools: parsing new benchmarks: %w", err)
+	}
+	names := make([]string, 0, len(r1)+len(r2))
+	for name := range r1 {
+		names = append(names, name)
+	}
+	for name := range r2 {
+		if _, ok := r1[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	root := &Difference{}
+	for _, name := range names {
+		path := jsonKeyStep(name)
+		m1, ok1 := r1[name]
+		m2, ok2 := r2[name]
+		switch {
+		case !ok1:
+			root.Children = append(root.Children, &Difference{Path: path, Kind: Inserted, Message: "only in new", Right: medians(m2), RightText: benchText(m2)})
+			continue
+		case !ok2:
+			root.Children = append(root.Children, &Difference{Path: path, Kind: Deleted, Message: "only in old", Left: medians(m1), LeftText: benchText(m1)})
+			continue
+		}
+		node := &Difference{Path: path}
+		for _, unit := range benchUnits {
+			s1, ok1 := m1[unit]
+			s2, ok2 := m2[unit]
+			if !ok1 || !ok2 {
+				continue
+			}
+			v1, v2 := median(s1), median(s2)
+			threshold := defaultBenchThreshold
+			if t, ok := o.benchThresholds[unit]; ok {
+				threshold = t
+			}
+			change := relativeChange(v1, v2)
+			if math.Abs(change) <= threshold {
+				continue
+			}
+			verdict := "regression"
+			if change < 0 {
+				verdict = "improvement"
+			}
+			node.Children = append(node.Children, &Difference{
+				Path:      path + jsonKeyStep(unit),
+				Kind:      Modified,
+				Message:   fmt.Sprintf("%s → %s (%+.1f%%), %s", benchNumber(v1), benchNumber(v2), 100*change, verdict),
+				Left:      v1,
+				LeftText:  benchNumber(v1),
+				Right:     v2,
+				RightText: benchNumber(v2),
+			})
+		}
+		if len(node.Children) > 0 {
+			root.Children = append(root.Children, node)
+		}
+	}
+	if len(root.Children) == 0 {
+		return &DiffTree{}, nil
+	}
+	return &DiffTree{Root: root}, nil
+}
+
+// parseBenchmarks returns the values measured for each metric of each
+// benchmark in the output of go test -bench, such as
+//
+//	BenchmarkParse-8   	  923101	      1234 ns/op	     128 B/op	       2 allocs/op
+func parseBenchmarks(out []byte) (map[string]map[string][]float64, error) {
+	results := make(map[string]map[string][]float64)
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				break
+			}
+			if results[fields[0]] == nil {
+				results[fields[0]] = make(map[string][]float64)
+			}
+			results[fields[0]][fields[i+1]] = append(results[fields[0]][fields[i+1]], v)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, errors.New("no benchmark results")
+	}
+	return results, nil
+}
+
+// relativeChange returns the change from v1 to v2 as a fraction of v1.
+func relativeChange(v1, v2 float64) float64 {
+	if v1 == 0 {
+		if v2 == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return (v2 - v1) / v1
+}
+
+func median(vs []float64) float64 {
+	s := append([]float64(nil), vs...)
+	sort.Float64s(s)
+	if n := len(s); n%2 == 0 {
+		return (s[n/2-1] + s[n/2]) / 2
+	}
+	return s[len(s)/2]
+}
+
+// medians returns the median of each metric of a benchmark.
+func medians(metrics map[string][]float64) map[string]float64 {
+	m := make(map[string]float64, len(metrics))
+	for unit, vs := range metrics {
+		m[unit] = median(vs)
+	}
+	return m
+}
+
+// benchText formats the compared metrics of a benchmark, such as
+// "1234 ns/op, 128 B/op, 2 allocs/op".
+func benchText(metrics map[string][]float64) string {
+	var parts []string
+	for _, unit := range benchUnits {
+		if vs, ok := metrics[unit]; ok {
+			parts = append(parts, benchNumber(median(vs))+" "+unit)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func benchNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,71 @@
+package debugtools
+
+import (
+	"math/big"
+	"reflect"
+)
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	bigRatType   = reflect.TypeOf(big.Rat{})
+)
+
+func isBig(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == bigIntType || t == bigFloatType || t == bigRatType
+}
+
+// bigPointer returns a pointer to the big number in v, which may be the
+// number or a pointer to it. It reports false if v is unexported.
+func bigPointer(v reflect.Value) (reflect.Value, bool) {
+	switch {
+	case !v.CanInterface():
+		return reflect.Value{}, false
+	case v.Kind() == reflect.Ptr:
+		return v, true
+	case v.CanAddr():
+		return v.Addr(), true
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p, true
+}
+
+// bigEqual compares big.Ints, big.Floats and big.Rats, or pointers to them,
+// by value using their Cmp methods rather than structurally, since the same
+// number can be held in differently sized backing arrays. It reports
+// ok=false for unexported values, which are compared structurally.
+func (s *deepEqualState) bigEqual(v1, v2 reflect.Value) (eq, ok bool) {
+	p1, ok1 := bigPointer(v1)
+	p2, ok2 := bigPointer(v2)
+	if !ok1 || !ok2 {
+		return false, false
+	}
+	if p1.IsNil() || p2.IsNil() {
+		s.println("Comparing pointers of type:", v1.Type())
+		s.println("  One of the pointers is nil, so not equal")
+		return s.report(p1.IsNil() == p2.IsNil(), "One of the pointers is nil"), true
+	}
+	var cmp int
+	var str1, str2 string
+	switch x := p1.Interface().(type) {
+	case *big.Int:
+		y := p2.Interface().(*big.Int)
+		cmp, str1, str2 = x.Cmp(y), x.String(), y.String()
+	case *big.Float:
+		y := p2.Interface().(*big.Float)
+		cmp, str1, str2 = x.Cmp(y), x.Text('g', -1), y.Text('g', -1)
+	case *big.Rat:
+		y := p2.Interface().(*big.Rat)
+		cmp, str1, str2 = x.Cmp(y), x.RatString(), y.RatString()
+	}
+	if cmp == 0 {
+		s.printf("%s == %s (%s)\n", str1, str2, v1.Type())
+		return s.report(true, "%s == %s (%s)", str1, str2, v1.Type()), true
+	}
+	s.printf("%s != %s (%s)\n", str1, str2, v1.Type())
+	return s.report(false, "%s != %s (%s)", str1, str2, v1.Type()), true
+}
@@ -0,0 +1,142 @@
+package debugtools
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/pib/go-debugtools/textdiff"
+)
+
+const (
+	// largeBytes is the length from which byte slices are compared by
+	// chunks rather than element by element.
+	largeBytes = 4096
+	// maxBlobRanges is the most changed ranges listed in the trace.
+	maxBlobRanges = 10
+
+	// Chunk boundaries fall where the low bits of a rolling hash are zero,
+	// giving chunks of about 512 bytes between the minimum and maximum.
+	chunkMask = 1<<9 - 1
+	minChunk  = 64
+	maxChunk  = 8192
+)
+
+// gearTable holds the random values mixed into the rolling hash for each
+// byte, generated with splitmix64 so that chunking is deterministic.
+var gearTable = func() (t [256]uint64) {
+	x := uint64(0x9e3779b97f4a7c15)
+	for i := range t {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+		z = (z ^ z>>27) * 0x94d049bb133111eb
+		t[i] = z ^ z>>31
+	}
+	return t
+}()
+
+// chunkBytes splits b into content-defined chunks, returning the offset at
+// which each starts. Since boundaries depend only on the bytes just before
+// them, an insertion or deletion changes only the chunks around it, and
+// the rest of the chunks still match up.
+func chunkBytes(b []byte) []int {
+	var starts []int
+	var h uint64
+	start := 0
+	for i, c := range b {
+		h = h<<1 + gearTable[c]
+		if n := i + 1 - start; n >= maxChunk || n >= minChunk && h&chunkMask == 0 {
+			starts = append(starts, start)
+			start, h = i+1, 0
+		}
+	}
+	if start < len(b) || len(b) == 0 {
+		starts = append(starts, start)
+	}
+	return starts
+}
+
+// A byteRange is a changed range of bytes on each side.
+type byteRange struct {
+	lo1, hi1, lo2, hi2 int
+}
+
+// changedRanges aligns the chunks of b1 and b2 and returns the ranges that
+// differ, with any bytes the two sides of a range have in common at its
+// ends trimmed off.
+func changedRanges(b1, b2 []byte) []byteRange {
+	c1, c2 := chunkBytes(b1), chunkBytes(b2)
+	chunk := func(b []byte, starts []int, i int) []byte {
+		if i+1 < len(starts) {
+			return b[starts[i]:starts[i+1]]
+		}
+		return b[starts[i]:]
+	}
+	ops := textdiff.Align(len(c1), len(c2), func(i, j int) bool {
+		return bytes.Equal(chunk(b1, c1, i), chunk(b2, c2, j))
+	})
+	var ranges []byteRange
+	i, j := 0, 0
+	for k := 0; k < len(ops); {
+		if ops[k] == textdiff.Equal {
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+		r := byteRange{lo1: c1[min(i, len(c1)-1)], lo2: c2[min(j, len(c2)-1)]}
+		if i == len(c1) {
+			r.lo1 = len(b1)
+		}
+		if j == len(c2) {
+			r.lo2 = len(b2)
+		}
+		for ; k < len(ops) && ops[k] != textdiff.Equal; k++ {
+			if ops[k] == textdiff.Delete {
+				i++
+			} else {
+				j++
+			}
+		}
+		r.hi1, r.hi2 = len(b1), len(b2)
+		if i < len(c1) {
+			r.hi1 = c1[i]
+		}
+		if j < len(c2) {
+			r.hi2 = c2[j]
+		}
+		for r.lo1 < r.hi1 && r.lo2 < r.hi2 && b1[r.lo1] == b2[r.lo2] {
+			r.lo1, r.lo2 = r.lo1+1, r.lo2+1
+		}
+		for r.lo1 < r.hi1 && r.lo2 < r.hi2 && b1[r.hi1-1] == b2[r.hi2-1] {
+			r.hi1, r.hi2 = r.hi1-1, r.hi2-1
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// blobEqual compares two large byte slices by content-defined chunks, and
+// reports the ranges of bytes that changed with their offsets and sizes,
+// rather than comparing and tracing them byte by byte. It reports ok=false
+// if just one of the slices is nil, which is reported in the usual way.
+func (s *deepEqualState) blobEqual(v1, v2 reflect.Value) (eq, ok bool) {
+	if v1.IsNil() != v2.IsNil() {
+		return false, false
+	}
+	b1, b2 := v1.Bytes(), v2.Bytes()
+	if bytes.Equal(b1, b2) {
+		s.printf("Byte slices of length %d are equal\n", len(b1))
+		return s.report(true, "Byte slices of length %d are equal", len(b1)), true
+	}
+	ranges := changedRanges(b1, b2)
+	s.printf("Byte slices of length %d and %d differ in %d ranges:\n", len(b1), len(b2), len(ranges))
+	for i, r := range ranges {
+		if i == maxBlobRanges {
+			s.printf("  ... and %d more\n", len(ranges)-i)
+			break
+		}
+		s.printf("  left[%d:%d] (%d bytes) != right[%d:%d] (%d bytes)\n", r.lo1, r.hi1, r.hi1-r.lo1, r.lo2, r.hi2, r.hi2-r.lo2)
+	}
+	r := ranges[0]
+	return s.report(false, "Byte slices of length %d and %d differ in %d ranges, first left[%d:%d] != right[%d:%d]",
+		len(b1), len(b2), len(ranges), r.lo1, r.hi1, r.lo2, r.hi2), true
+}
@@ -0,0 +1,408 @@
+// Command deepequalgen generates comparators for struct types that compare
+// values as debugtools.DeepEqual does, without reflection, for hot paths
+// where its cost matters. For each type Xxx it writes
+//
+//	func DeepEqualXxx(a, b *Xxx) (bool, []debugtools.Difference)
+//
+// which reports whether *a and *b are deeply equal and, if not, returns
+// their differences, as the leaves of the DiffTree from debugtools.Diff.
+// It is meant to be run by go generate, in the package declaring the
+// types:
+//
+//	//go:generate go run github.com/pib/go-debugtools/cmd/deepequalgen -type Order,Item
+//
+// The generated code compares fields of basic types, and arrays, slices,
+// maps and pointers of them and of the listed types, itself. Other
+// values, such as interfaces and types from other packages, are compared
+// by debugtools.EqualValues, and the differences found are described by
+// debugtools.AppendDiff, so that the results are those of the runtime
+// comparison with no Options. Fields tagged `deepequal:"json"` are
+// compared as JSON, as at run time. Unlike DeepEqual, the generated
+// comparators don't detect cycles, so values linked by pointers between
+// the listed types must not form one, and they skip blank fields.
+//
+// The comparators are written to <type>_deepequal.go, after the first
+// type listed, unless -output says otherwise, along with unexported
+// helpers named equalXxx and diffXxx.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of struct type names; required")
+	output := flag.String("output", "", "output file name; default <type>_deepequal.go")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: deepequalgen -type T[,T...] [-output file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeNames == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	names := strings.Split(*typeNames, ",")
+	if *output == "" {
+		*output = filepath.Join(dir, strings.ToLower(names[0])+"_deepequal.go")
+	}
+	pkg, err := loadPackage(dir, filepath.Base(*output))
+	if err != nil {
+		fatal(err)
+	}
+	g := &generator{pkg: pkg, listed: make(map[*types.TypeName]bool)}
+	for _, name := range names {
+		obj, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok {
+			fatal(fmt.Errorf("no type %s in package %s", name, pkg.Name()))
+		}
+		if _, ok := obj.Type().Underlying().(*types.Struct); !ok {
+			fatal(fmt.Errorf("%s is not a struct type", name))
+		}
+		g.types = append(g.types, obj)
+		g.listed[obj] = true
+	}
+	src, err := g.generate(strings.Join(os.Args[1:], " "))
+	if err != nil {
+		fatal(err)
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "deepequalgen: %v\n", err)
+	os.Exit(1)
+}
+
+// loadPackage parses and type checks the package in dir, leaving out the
+// file skip, which an earlier run may have generated.
+func loadPackage(dir, skip string) (*types.Package, error) {
+	bp, err := build.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		if name == skip {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	return conf.Check(bp.ImportPath, fset, files, nil)
+}
+
+// A generator writes the comparators for a package's listed types.
+type generator struct {
+	pkg    *types.Package
+	types  []*types.TypeName
+	listed map[*types.TypeName]bool
+	buf    bytes.Buffer
+	// vars counts the loop variables declared so far, to name them.
+	vars int
+	// strconv is set once the generated code formats array indexes, and
+	// unsafe once it compares maps.
+	strconv, unsafe bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// generate returns the source of the comparators, formatted.
+func (g *generator) generate(args string) ([]byte, error) {
+	for _, t := range g.types {
+		g.comparator(t)
+	}
+	body := g.buf.Bytes()
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by \"deepequalgen %s\"; DO NOT EDIT.\n\n", args)
+	fmt.Fprintf(&src, "package %s\n\n", g.pkg.Name())
+	src.WriteString("import (\n")
+	if g.strconv {
+		src.WriteString("\t\"strconv\"\n")
+	}
+	if g.unsafe {
+		src.WriteString("\t\"unsafe\"\n")
+	}
+	if g.strconv || g.unsafe {
+		src.WriteString("\n")
+	}
+	src.WriteString("\tdebugtools \"github.com/pib/go-debugtools\"\n)\n")
+	src.Write(body)
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v\n%s", err, src.Bytes())
+	}
+	return out, nil
+}
+
+// comparator writes DeepEqualXxx and its helpers for the listed type t.
+func (g *generator) comparator(t *types.TypeName) {
+	name := t.Name()
+	st := t.Type().Underlying().(*types.Struct)
+	g.printf(`
+// DeepEqual%[1]s reports whether *a and *b are deeply equal, as
+// debugtools.DeepEqual decides, and if not, returns their differences, as
+// the leaves of debugtools.Diff.
+func DeepEqual%[1]s(a, b *%[1]s) (bool, []debugtools.Difference) {
+	if equal%[1]s(a, b) {
+		return true, nil
+	}
+	return false, diff%[1]s(nil, "", a, b)
+}
+
+func equal%[1]s(a, b *%[1]s) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+`, name)
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if f.Name() == "_" {
+			continue
+		}
+		g.printf("if !(%s) {\nreturn false\n}\n", g.equal("a."+f.Name(), "b."+f.Name(), f.Type(), jsonTag(st.Tag(i))))
+	}
+	g.printf(`return true
+}
+
+func diff%[1]s(diffs []debugtools.Difference, path string, a, b *%[1]s) []debugtools.Difference {
+	if a == nil || b == nil {
+		if a != b {
+			diffs = debugtools.AppendDiff(diffs, path, &a, &b)
+		}
+		return diffs
+	}
+`, name)
+	for i := 0; i < st.NumFields(); i++ {
+		f := st.Field(i)
+		if f.Name() == "_" {
+			continue
+		}
+		g.diff("a."+f.Name(), "b."+f.Name(), fmt.Sprintf("path + %q", "."+f.Name()), f.Type(), jsonTag(st.Tag(i)))
+	}
+	g.printf("return diffs\n}\n")
+}
+
+// equal returns an expression reporting whether x and y, addressable
+// expressions of type t, are deeply equal. asJSON is set for a field
+// tagged `deepequal:"json"`.
+func (g *generator) equal(x, y string, t types.Type, asJSON bool) string {
+	if named := g.listedType(t); named != nil {
+		return fmt.Sprintf("equal%s(&%s, &%s)", named.Name(), x, y)
+	}
+	if named := g.listedPointer(t); named != nil {
+		return fmt.Sprintf("equal%s(%s, %s)", named.Name(), x, y)
+	}
+	if asJSON && isBytes(t) {
+		return fmt.Sprintf("debugtools.EqualJSON(&%s, &%s)", x, y)
+	}
+	if _, ok := t.Underlying().(*types.Basic); ok && !isValuer(t) {
+		return fmt.Sprintf("%s == %s", x, y)
+	}
+	if !g.generated(t) {
+		if !safeComparable(t) {
+			return fmt.Sprintf("debugtools.EqualValues(&%s, &%s)", x, y)
+		}
+		return fmt.Sprintf("(%s == %s || debugtools.EqualValues(&%s, &%s))", x, y, x, y)
+	}
+	var body bytes.Buffer
+	switch u := t.Underlying().(type) {
+	case *types.Array:
+		i := g.newVar("i")
+		fmt.Fprintf(&body, "for %s := range %s {\nif !(%s) {\nreturn false\n}\n}\n",
+			i, x, g.equal(x+"["+i+"]", y+"["+i+"]", u.Elem(), false))
+	case *types.Slice:
+		i := g.newVar("i")
+		fmt.Fprintf(&body, "if (%[1]s == nil) != (%[2]s == nil) || len(%[1]s) != len(%[2]s) {\nreturn false\n}\n", x, y)
+		// Slices sharing their elements are equal, as at run time.
+		fmt.Fprintf(&body, "if len(%[1]s) > 0 && &%[1]s[0] == &%[2]s[0] {\nreturn true\n}\n", x, y)
+		fmt.Fprintf(&body, "for %s := range %s {\nif !(%s) {\nreturn false\n}\n}\n",
+			i, x, g.equal(x+"["+i+"]", y+"["+i+"]", u.Elem(), false))
+	case *types.Map:
+		k, v, w := g.newVar("k"), g.newVar("v"), g.newVar("w")
+		fmt.Fprintf(&body, "if (%[1]s == nil) != (%[2]s == nil) || len(%[1]s) != len(%[2]s) {\nreturn false\n}\n", x, y)
+		// The same map is equal to itself, as at run time, even if it
+		// holds NaNs. A map value is a pointer to the map, which can't be
+		// compared with == in Go.
+		g.unsafe = true
+		fmt.Fprintf(&body, "if *(*unsafe.Pointer)(unsafe.Pointer(&%[1]s)) == *(*unsafe.Pointer)(unsafe.Pointer(&%[2]s)) {\nreturn true\n}\n", x, y)
+		fmt.Fprintf(&body, "for %s, %s := range %s {\n%s, ok := %s[%s]\nif !ok || !(%s) {\nreturn false\n}\n}\n",
+			k, v, x, w, y, k, g.equal(v, w, u.Elem(), false))
+	case *types.Pointer:
+		fmt.Fprintf(&body, "if %[1]s == %[2]s {\nreturn true\n}\nif %[1]s == nil || %[2]s == nil {\nreturn false\n}\n", x, y)
+		fmt.Fprintf(&body, "return %s\n", g.equal("(*"+x+")", "(*"+y+")", u.Elem(), false))
+		return fmt.Sprintf("func() bool {\n%s}()", body.String())
+	}
+	return fmt.Sprintf("func() bool {\n%sreturn true\n}()", body.String())
+}
+
+// diff writes the statements appending the differences between x and y,
+// addressable expressions of type t, to diffs, with path the expression
+// for their path.
+func (g *generator) diff(x, y, path string, t types.Type, asJSON bool) {
+	if named := g.listedType(t); named != nil {
+		g.printf("if !equal%[1]s(&%[2]s, &%[3]s) {\ndiffs = diff%[1]s(diffs, %[4]s, &%[2]s, &%[3]s)\n}\n", named.Name(), x, y, path)
+		return
+	}
+	if named := g.listedPointer(t); named != nil {
+		g.printf("if !equal%[1]s(%[2]s, %[3]s) {\ndiffs = diff%[1]s(diffs, %[4]s, %[2]s, %[3]s)\n}\n", named.Name(), x, y, path)
+		return
+	}
+	if asJSON && isBytes(t) {
+		g.printf("if !debugtools.EqualJSON(&%[1]s, &%[2]s) {\ndiffs = debugtools.AppendJSONDiff(diffs, %[3]s, &%[1]s, &%[2]s)\n}\n", x, y, path)
+		return
+	}
+	if u, ok := t.Underlying().(*types.Array); ok && g.generated(t) {
+		// Arrays are compared element by element at run time too, so
+		// their differences are found the same way here.
+		i := g.newVar("i")
+		g.strconv = true
+		g.printf("for %s := range %s {\n", i, x)
+		g.diff(x+"["+i+"]", y+"["+i+"]", fmt.Sprintf(`%s + "[" + strconv.Itoa(%s) + "]"`, path, i), u.Elem(), false)
+		g.printf("}\n")
+		return
+	}
+	// Slices and maps are aligned by the runtime comparison, which is left
+	// to describe their differences.
+	g.printf("if !(%[1]s) {\ndiffs = debugtools.AppendDiff(diffs, %[2]s, &%[3]s, &%[4]s)\n}\n", g.equal(x, y, t, false), path, x, y)
+}
+
+// listedType returns the listed type t is, if it is one.
+func (g *generator) listedType(t types.Type) *types.TypeName {
+	if named, ok := types.Unalias(t).(*types.Named); ok && g.listed[named.Obj()] {
+		return named.Obj()
+	}
+	return nil
+}
+
+// listedPointer returns the listed type t points to, if it does.
+func (g *generator) listedPointer(t types.Type) *types.TypeName {
+	if p, ok := t.(*types.Pointer); ok {
+		return g.listedType(p.Elem())
+	}
+	return nil
+}
+
+// generated reports whether the generated code compares the composite
+// values of type t element by element, which it does for arrays, slices,
+// maps and pointers that aren't of types from other packages, since those
+// may be given special treatment by the runtime comparison, and that lead
+// to values the generated code compares itself.
+func (g *generator) generated(t types.Type) bool {
+	if named, ok := types.Unalias(t).(*types.Named); ok && named.Obj().Pkg() != g.pkg {
+		return false
+	}
+	if isValuer(t) {
+		return false
+	}
+	var elem types.Type
+	switch u := t.Underlying().(type) {
+	case *types.Array:
+		elem = u.Elem()
+	case *types.Slice:
+		elem = u.Elem()
+	case *types.Map:
+		elem = u.Elem()
+	case *types.Pointer:
+		elem = u.Elem()
+	default:
+		return false
+	}
+	if g.listedType(elem) != nil || g.listedPointer(elem) != nil {
+		return true
+	}
+	if _, ok := elem.Underlying().(*types.Basic); ok {
+		return !isValuer(elem)
+	}
+	return g.generated(elem)
+}
+
+// newVar returns a new variable name starting with prefix.
+func (g *generator) newVar(prefix string) string {
+	g.vars++
+	return fmt.Sprintf("%s%d", prefix, g.vars)
+}
+
+// jsonTag reports whether the struct tag marks its field for comparison as
+// JSON.
+func jsonTag(tag string) bool {
+	for _, flag := range strings.Split(reflect.StructTag(tag).Get("deepequal"), ",") {
+		if strings.TrimSpace(flag) == "json" {
+			return true
+		}
+	}
+	return false
+}
+
+// isBytes reports whether t is a byte slice.
+func isBytes(t types.Type) bool {
+	s, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	b, ok := s.Elem().Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Byte
+}
+
+// isValuer reports whether values of type t have a Value method, as the
+// driver.Valuers the runtime comparison compares by their values do.
+func isValuer(t types.Type) bool {
+	ms := types.NewMethodSet(t)
+	for i := 0; i < ms.Len(); i++ {
+		if ms.At(i).Obj().Name() == "Value" {
+			return true
+		}
+	}
+	return false
+}
+
+// safeComparable reports whether values of type t can be compared with ==
+// without the risk of a panic, which interfaces holding uncomparable
+// values cause.
+func safeComparable(t types.Type) bool {
+	if !types.Comparable(t) {
+		return false
+	}
+	switch u := t.Underlying().(type) {
+	case *types.Interface:
+		return false
+	case *types.Array:
+		return safeComparable(u.Elem())
+	case *types.Struct:
+		for i := 0; i < u.NumFields(); i++ {
+			if !safeComparable(u.Field(i).Type()) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+// Command snapreview reviews the values received in place of snapshots by
+// tests run with -review, as the snapshot package writes them:
+//
+//	snapreview [-dir testdata/snapshots] list
+//	snapreview [-dir testdata/snapshots] diff [name ...]
+//	snapreview [-dir testdata/snapshots] approve [name ...]
+//	snapreview [-dir testdata/snapshots] reject [name ...]
+//
+// list prints the snapshots awaiting review, diff prints the differences
+// between each and the value received, approve replaces each with the
+// value received, and reject discards the value received. A name is the
+// path of a snapshot relative to the directory, with or without its .snap
+// extension, such as TestRender/dark_mode; with no names, diff, approve
+// and reject apply to every snapshot awaiting review.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pib/go-debugtools/snapshot"
+)
+
+func main() {
+	dir := flag.String("dir", filepath.Join("testdata", "snapshots"), "directory holding the snapshots")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: snapreview [-dir dir] list | diff|approve|reject [name ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	pending, err := snapshot.PendingReview(*dir)
+	if err != nil {
+		fatal(err)
+	}
+	pending, err = selectPending(*dir, pending, flag.Args()[1:])
+	if err != nil {
+		fatal(err)
+	}
+	switch cmd := flag.Arg(0); cmd {
+	case "list":
+		for _, p := range pending {
+			fmt.Println(name(*dir, p))
+		}
+	case "diff":
+		for _, p := range pending {
+			diff, err := p.Diff()
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Printf("%s:\n%s\n", name(*dir, p), strings.TrimSuffix(diff, "\n"))
+		}
+	case "approve", "reject":
+		for _, p := range pending {
+			done := "approved"
+			if cmd == "approve" {
+				err = p.Approve()
+			} else {
+				err, done = p.Reject(), "rejected"
+			}
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Printf("%s %s\n", done, name(*dir, p))
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// selectPending returns the snapshots among pending with the given names,
+// or all of them if there are no names.
+func selectPending(dir string, pending []snapshot.Pending, names []string) ([]snapshot.Pending, error) {
+	if len(names) == 0 {
+		return pending, nil
+	}
+	var selected []snapshot.Pending
+	for _, n := range names {
+		found := false
+		for _, p := range pending {
+			if name(dir, p) == strings.TrimSuffix(filepath.ToSlash(n), ".snap") {
+				selected = append(selected, p)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no snapshot %s awaiting review", n)
+		}
+	}
+	return selected, nil
+}
+
+// name returns the name of a pending snapshot: its path relative to dir,
+// without the .snap extension.
+func name(dir string, p snapshot.Pending) string {
+	rel, err := filepath.Rel(dir, p.Snapshot)
+	if err != nil {
+		rel = p.Snapshot
+	}
+	return strings.TrimSuffix(filepath.ToSlash(rel), ".snap")
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "snapreview:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,125 @@
+package debugtools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// A DiffCollector accumulates the differences found across the cases of a
+// table-driven test, to report them together, grouped by path, when the
+// test ends. A breakage common to many cases then shows up as one path
+// listing every case, rather than scattered among per-case failures:
+//
+//	c := debugtools.NewDiffCollector(t)
+//	for _, tc := range cases {
+//		c.Check(tc.name, tc.want, convert(tc.in))
+//	}
+//
+// which fails the test with a report such as
+//
+//	3 of 4 cases differ
+//	.Currency: 3 cases
+//		usd: "USD" != "usd"
+//		eur: "EUR" != "eur"
+//		gbp: "GBP" != "gbp"
+//
+// It is safe for concurrent use, so cases can be checked from parallel
+// subtests.
+type DiffCollector struct {
+	opts []Option
+
+	mu     sync.Mutex
+	cases  int
+	failed int
+	byPath map[string][]caseChange
+}
+
+// A caseChange is a change found at some path in a case.
+type caseChange struct {
+	name, change string
+}
+
+// NewDiffCollector returns a DiffCollector comparing with opts, which fails
+// t with its report when t and its subtests have finished, if any case
+// differed.
+func NewDiffCollector(t testing.TB, opts ...Option) *DiffCollector {
+	c := &DiffCollector{opts: opts, byPath: make(map[string][]caseChange)}
+	t.Cleanup(func() {
+		if report := c.Report(); report != "" {
+			fail(t, c.opts, report)
+		}
+	})
+	return c
+}
+
+// Check compares got against want, as Diff does, records the differences
+// under the case name, and reports whether there were none.
+func (c *DiffCollector) Check(name string, want, got interface{}) bool {
+	var changes []*Difference
+	if d, err := Diff(want, got, c.opts...); err != nil {
+		changes = []*Difference{{Message: fmt.Sprintf("types don't match: want %T, got %T", want, got)}}
+	} else {
+		changes = d.Leaves()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cases++
+	if len(changes) == 0 {
+		return true
+	}
+	c.failed++
+	for _, n := range changes {
+		c.byPath[n.Path] = append(c.byPath[n.Path], caseChange{name: name, change: leafChange(n)})
+	}
+	return false
+}
+
+// Report returns the consolidated report of the differences found so far,
+// with the paths differing in the most cases first, or "" if no case
+// differed.
+func (c *DiffCollector) Report() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failed == 0 {
+		return ""
+	}
+	paths := make([]string, 0, len(c.byPath))
+	for p := range c.byPath {
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		ni, nj := len(c.byPath[paths[i]]), len(c.byPath[paths[j]])
+		if ni != nj {
+			return ni > nj
+		}
+		return paths[i] < paths[j]
+	})
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d of %d cases differ", c.failed, c.cases)
+	for _, p := range paths {
+		changes := c.byPath[p]
+		fmt.Fprintf(&sb, "\n%s: %d case", displayPath(p), len(changes))
+		if len(changes) != 1 {
+			sb.WriteString("s")
+		}
+		for _, ch := range changes {
+			fmt.Fprintf(&sb, "\n\t%s: %s", ch.name, ch.change)
+		}
+	}
+	return sb.String()
+}
+
+// leafChange describes the change at a leaf of a DiffTree, as
+// TextFormatter does without the path.
+func leafChange(n *Difference) string {
+	switch n.Kind {
+	case Inserted:
+		return "+ " + n.RightText
+	case Deleted:
+		return "- " + n.LeftText
+	}
+	return n.Message
+}
@@ -0,0 +1,111 @@
+package debugtools
+
+import (
+	"reflect"
+	"sync"
+)
+
+// A Comparer compares values as DeepEqual does with a set of Options,
+// which are applied once, when it is made, rather than on every call, and
+// it works out which of them apply to each type it meets once too. It
+// also keeps the memory its comparisons use, such as the set of visited
+// pointers, the path stack and the trace buffer, for reuse by later ones,
+// so that a loop comparing many values doesn't allocate it anew each time:
+//
+//	cmp := debugtools.NewComparer(debugtools.IgnorePaths("UpdatedAt"))
+//	for _, rec := range records {
+//		if eq, trace := cmp.DeepEqual(rec, want[rec.ID]); !eq {
+//			log.Printf("record %d differs:\n%s", rec.ID, trace)
+//		}
+//	}
+//
+// A Comparer is safe for concurrent use, as long as any Reporter it was
+// given is.
+type Comparer struct {
+	o      *options
+	states statePool
+}
+
+// NewComparer returns a Comparer comparing with opts.
+func NewComparer(opts ...Option) *Comparer {
+	return newComparer(newOptions(opts))
+}
+
+// newComparer returns a Comparer comparing with o, which it caches the
+// plans of its comparisons in.
+func newComparer(o *options) *Comparer {
+	o.plans = &sync.Map{}
+	return &Comparer{o: o}
+}
+
+// DeepEqual is like the package-level DeepEqual, with c's Options.
+func (c *Comparer) DeepEqual(a1, a2 interface{}) (bool, string) {
+	if a1 == nil || a2 == nil {
+		return a1 == a2, ""
+	}
+	return equalValues(&c.states, c.o, reflect.ValueOf(a1), reflect.ValueOf(a2))
+}
+
+// DeepValueEqual is like the package-level DeepValueEqual, with c's
+// Options.
+func (c *Comparer) DeepValueEqual(v1, v2 reflect.Value) (bool, string) {
+	return equalValues(&c.states, c.o, v1, v2)
+}
+
+// states holds the comparison state reused by the package-level functions.
+var states statePool
+
+// The largest visited set, frame stack and trace buffer kept for reuse, so
+// that a single comparison of a huge value doesn't pin its memory.
+const (
+	maxPooledVisits = 1 << 10
+	maxPooledFrames = 1 << 10
+	maxPooledTrace  = 64 << 10
+)
+
+// A statePool holds deepEqualStates for reuse by later comparisons.
+type statePool struct {
+	pool sync.Pool
+}
+
+// get returns a state, ready to start a comparison with o.
+func (p *statePool) get(o *options) *deepEqualState {
+	s, _ := p.pool.Get().(*deepEqualState)
+	if s == nil {
+		s = &deepEqualState{}
+	}
+	*s = deepEqualState{
+		visited: s.visited,
+		path:    s.path[:0],
+		stack:   s.stack[:0],
+		buf:     s.buf,
+		line:    s.line[:0],
+		depth:   -1,
+		opts:    o,
+		pool:    p,
+	}
+	return s
+}
+
+// put returns s to the pool once its comparison has finished.
+func (p *statePool) put(s *deepEqualState) {
+	s.visited.reset(maxPooledVisits)
+	if s.buf != nil {
+		if s.buf.Cap() > maxPooledTrace {
+			s.buf = nil
+		} else {
+			s.buf.Reset()
+			s.buf.full, s.buf.dropped = false, 0
+		}
+	}
+	if cap(s.line) > maxPooledTrace {
+		s.line = nil
+	}
+	if cap(s.stack) > maxPooledFrames {
+		s.stack = nil
+	}
+	clear(s.path)
+	clear(s.stack)
+	*s = deepEqualState{visited: s.visited, path: s.path[:0], stack: s.stack[:0], buf: s.buf, line: s.line[:0]}
+	p.pool.Put(s)
+}
@@ -0,0 +1,132 @@
+package debugtools
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// A Config bundles a list of Options, so that a test package can define
+// how it compares and dumps values once and use it everywhere:
+//
+//	var cmp = debugtools.NewConfig(
+//		debugtools.IgnorePaths("ID", "CreatedAt"),
+//		debugtools.EquateNumericKinds(),
+//	)
+//
+//	func TestOrder(t *testing.T) {
+//		cmp.Assert(t, want, placeOrder())
+//	}
+//
+// It also gives a read-only view of the settings, for adapters that run
+// other comparison libraries with the same configuration as DeepEqual.
+type Config struct {
+	o    *options
+	opts []Option
+	cmp  *Comparer
+}
+
+// NewConfig applies opts and returns the resulting settings.
+func NewConfig(opts ...Option) *Config {
+	opts = append([]Option(nil), opts...)
+	o := newOptions(opts)
+	return &Config{o: o, opts: opts, cmp: newComparer(o)}
+}
+
+// With returns a Config with c's Options followed by opts, which can add
+// to or override them for a single test.
+func (c *Config) With(opts ...Option) *Config {
+	return NewConfig(append(c.opts[:len(c.opts):len(c.opts)], opts...)...)
+}
+
+// Options returns c's Options, for the functions that take them.
+func (c *Config) Options() []Option {
+	return append([]Option(nil), c.opts...)
+}
+
+// DeepEqual is like the package-level DeepEqual, with c's Options.
+func (c *Config) DeepEqual(a1, a2 interface{}) (bool, string) {
+	return c.cmp.DeepEqual(a1, a2)
+}
+
+// Diff is like the package-level Diff, with c's Options.
+func (c *Config) Diff(a, b interface{}) (*DiffTree, error) {
+	return Diff(a, b, c.opts...)
+}
+
+// Assert checks that got is equal to want, as DeepEqual decides with c's
+// Options, and reports whether it is. If it isn't, t fails with their
+// differences.
+func (c *Config) Assert(t testing.TB, want, got interface{}) bool {
+	t.Helper()
+	if eq, _ := c.cmp.DeepEqual(want, got); eq {
+		return true
+	}
+	fail(t, c.opts, fmt.Sprintf("value differs from want:\n%s%s", describeDiff(want, got, c.opts), artifactsNote(t, c.opts, func() []artifact {
+		return valueArtifacts(want, got, c.opts)
+	})))
+	return false
+}
+
+// Dump is like the package-level Dump, with c's Options.
+func (c *Config) Dump(v interface{}) {
+	Dump(v, c.opts...)
+}
+
+// Sdump is like the package-level Sdump, with c's Options.
+func (c *Config) Sdump(v interface{}) string {
+	return Sdump(v, c.opts...)
+}
+
+// Fdump is like the package-level Fdump, with c's Options.
+func (c *Config) Fdump(w io.Writer, v interface{}) {
+	Fdump(w, v, c.opts...)
+}
+
+// Excluded reports whether the subtree at path, written as in Result.Path,
+// is skipped by IgnorePaths or OnlyPaths.
+func (c *Config) Excluded(path string) bool {
+	return c.o.excluded(path)
+}
+
+// HasPathFilter reports whether IgnorePaths or OnlyPaths was given.
+func (c *Config) HasPathFilter() bool {
+	return len(c.o.ignore) > 0 || len(c.o.only) > 0
+}
+
+// CanonicalMapKeys returns the canonicalization functions given to
+// CanonicalMapKeys, by map type.
+func (c *Config) CanonicalMapKeys() map[reflect.Type]func(key interface{}) interface{} {
+	m := make(map[reflect.Type]func(interface{}) interface{}, len(c.o.mapKeys))
+	for t, f := range c.o.mapKeys {
+		m[t] = f
+	}
+	return m
+}
+
+// DurationTolerance returns the tolerance given to WithDurationTolerance.
+func (c *Config) DurationTolerance() time.Duration {
+	return c.o.durationTolerance
+}
+
+// Normalizer returns the Normalizer given to NormalizeUnicode, or nil.
+func (c *Config) Normalizer() Normalizer {
+	return c.o.normalizer
+}
+
+// EquateNumericKinds reports whether EquateNumericKinds was given.
+func (c *Config) EquateNumericKinds() bool {
+	return c.o.equateNumeric
+}
+
+// ChanPolicy returns the policy given to WithChanPolicy.
+func (c *Config) ChanPolicy() ChanPolicy {
+	return c.o.chanPolicy
+}
+
+// Reporter returns the Reporter given to WithReporter, or nil.
+func (c *Config) Reporter() Reporter {
+	return c.o.reporter
+}
@@ -0,0 +1,184 @@
+package debugtools
+
+import (
+	"reflect"
+	"sort"
+	"unsafe"
+)
+
+// DeepCopy returns a copy of v that shares no memory with it: everything
+// reachable through pointers, slices, maps and interfaces is copied,
+// including unexported fields. Memory reachable along several paths is
+// copied once, so the copy has the same sharing and cycles as v: slices of
+// the same array are copied as slices of one copy of it, up to its
+// capacity. That holds as long as the first slice of an array reached
+// spans the others, as one not resliced to start later or to a smaller
+// capacity does. Channels, functions and unsafe pointers can't be copied,
+// and are shared.
+func DeepCopy[T any](v T) T {
+	c := &copier{copied: make(map[copyKey]reflect.Value), arrays: make(map[reflect.Type][]copiedArray)}
+	src := reflect.ValueOf(&v).Elem()
+	// The copy is made in place rather than asserted back to T from
+	// Interface, which panics for a nil interface.
+	var out T
+	c.copy(reflect.ValueOf(&out).Elem(), src)
+	return out
+}
+
+// MustDeepCopy is like DeepCopy, but checks that the copy is equal to v,
+// as DeepEqual decides with opts, and panics with the trace if it isn't,
+// as happens if v holds non-nil functions or NaNs, which DeepEqual never
+// considers equal. It is meant for initialization code and examples, where
+// a copy silently differing from its original would be a bug.
+func MustDeepCopy[T any](v T, opts ...Option) T {
+	c := DeepCopy(v)
+	if eq, trace := DeepEqual(v, c, opts...); !eq {
+		panic("debugtools: copy differs from the original:\n" + trace)
+	}
+	return c
+}
+
+// MustDeepEqual panics with the trace if a and b aren't equal, as DeepEqual
+// decides with opts, for checking invariants in initialization code and
+// examples, where returning an error is awkward.
+func MustDeepEqual(a, b interface{}, opts ...Option) {
+	if eq, trace := DeepEqual(a, b, opts...); !eq {
+		panic("debugtools: values not equal:\n" + trace)
+	}
+}
+
+// A copyKey identifies memory copied by DeepCopy.
+type copyKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// A copiedArray is the copy of the array from start up to end, as a slice
+// of its whole length.
+type copiedArray struct {
+	start, end uintptr
+	s          reflect.Value
+}
+
+// copier holds the progress of DeepCopy.
+type copier struct {
+	// copied holds the copy made of each pointer and map.
+	copied map[copyKey]reflect.Value
+	// arrays holds the copies of the arrays behind slices, by slice type,
+	// in order of address.
+	arrays map[reflect.Type][]copiedArray
+}
+
+// copy sets dst, which must be settable, to a deep copy of src.
+func (c *copier) copy(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		key := copyKey{ptr: src.Pointer(), typ: src.Type()}
+		if p, ok := c.copied[key]; ok {
+			dst.Set(p)
+			return
+		}
+		// Record the copy before filling it, so that cycles lead back to it.
+		p := reflect.New(src.Type().Elem())
+		c.copied[key] = p
+		c.copy(p.Elem(), src.Elem())
+		dst.Set(p)
+	case reflect.Interface:
+		if src.IsNil() {
+			return
+		}
+		e := reflect.New(src.Elem().Type()).Elem()
+		c.copy(e, src.Elem())
+		dst.Set(e)
+	case reflect.Struct:
+		if !src.CanAddr() {
+			// Make a copy whose unexported fields can be read.
+			a := reflect.New(src.Type()).Elem()
+			a.Set(src)
+			src = a
+		}
+		for i := 0; i < src.NumField(); i++ {
+			c.copy(exposed(dst.Field(i)), exposed(src.Field(i)))
+		}
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			c.copy(dst.Index(i), src.Index(i))
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		dst.Set(c.slice(src))
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		key := copyKey{ptr: src.Pointer(), typ: src.Type()}
+		if m, ok := c.copied[key]; ok {
+			dst.Set(m)
+			return
+		}
+		m := reflect.MakeMapWithSize(src.Type(), src.Len())
+		c.copied[key] = m
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(src.Type().Key()).Elem()
+			c.copy(k, iter.Key())
+			v := reflect.New(src.Type().Elem()).Elem()
+			c.copy(v, iter.Value())
+			m.SetMapIndex(k, v)
+		}
+		dst.Set(m)
+	default:
+		dst.Set(src)
+	}
+}
+
+// slice returns a copy of the non-nil slice src, as a slice of the copy of
+// its array made for an earlier slice if that spans it, and otherwise of a
+// new copy of its array up to its capacity.
+func (c *copier) slice(src reflect.Value) reflect.Value {
+	t := src.Type()
+	size := t.Elem().Size()
+	if size == 0 || src.Cap() == 0 {
+		// No memory to share.
+		s := reflect.MakeSlice(t, src.Len(), src.Cap())
+		for i := 0; i < src.Len(); i++ {
+			c.copy(s.Index(i), src.Index(i))
+		}
+		return s
+	}
+	start := src.Pointer()
+	end := start + uintptr(src.Cap())*size
+	arrays := c.arrays[t]
+	i := sort.Search(len(arrays), func(i int) bool { return arrays[i].start > start })
+	if i > 0 {
+		if a := arrays[i-1]; end <= a.end && (start-a.start)%size == 0 {
+			off := int((start - a.start) / size)
+			return a.s.Slice3(off, off+src.Len(), off+src.Cap())
+		}
+	}
+	// Record the copy before filling it, so that cycles lead back to it.
+	whole := reflect.MakeSlice(t, src.Cap(), src.Cap())
+	arrays = append(arrays, copiedArray{})
+	copy(arrays[i+1:], arrays[i:])
+	arrays[i] = copiedArray{start: start, end: end, s: whole}
+	c.arrays[t] = arrays
+	full := src.Slice3(0, src.Cap(), src.Cap())
+	for j := 0; j < full.Len(); j++ {
+		c.copy(whole.Index(j), full.Index(j))
+	}
+	return whole.Slice3(0, src.Len(), src.Cap())
+}
+
+// exposed returns the field f of an addressable struct as if it were
+// exported, so that it can be read and set.
+func exposed(f reflect.Value) reflect.Value {
+	if f.CanSet() {
+		return f
+	}
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+}
@@ -0,0 +1,36 @@
+package debugtools
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// CSVFormatter renders a DiffTree as CSV, with a header row and then one
+// "path,old,new,change_kind" row per change, for pasting into a
+// spreadsheet. Strings are written as they are, and other values as in
+// Difference.LeftText and RightText; a value is empty on the side it
+// doesn't exist.
+type CSVFormatter struct {
+	// Comma is the field delimiter, ',' if zero.
+	Comma rune
+}
+
+func (f CSVFormatter) FormatDiff(w io.Writer, d *DiffTree) error {
+	cw := csv.NewWriter(w)
+	if f.Comma != 0 {
+		cw.Comma = f.Comma
+	}
+	cw.Write([]string{"path", "old", "new", "change_kind"})
+	for _, n := range d.Leaves() {
+		cw.Write([]string{displayPath(n.Path), csvValue(n.Left, n.LeftText), csvValue(n.Right, n.RightText), n.Kind.String()})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvValue(v interface{}, text string) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return text
+}
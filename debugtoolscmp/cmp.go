@@ -0,0 +1,244 @@
+// Package debugtoolscmp adapts debugtools to github.com/google/go-cmp, so
+// that code moving between the two can run both with one configuration:
+// Options turns a list of debugtools Options into cmp Options, and Reporter
+// feeds cmp's results into a debugtools Reporter and trace.
+package debugtoolscmp
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	debugtools "github.com/pib/go-debugtools"
+)
+
+// Options converts opts into cmp Options that make cmp.Equal and cmp.Diff
+// compare as DeepEqual would with opts, as far as cmp allows:
+//
+//   - unexported fields are compared, as DeepEqual does;
+//   - big numbers, json.RawMessages and driver.Valuers are compared the
+//     way DeepEqual compares them;
+//   - IgnorePaths and OnlyPaths skip the same paths;
+//   - CanonicalMapKeys, WithDurationTolerance, NormalizeUnicode,
+//     EquateNumericKinds and WithChanPolicy are honored;
+//   - a Reporter given with WithReporter receives cmp's results.
+//
+// Options that only affect the trace have no equivalent and are ignored.
+func Options(opts ...debugtools.Option) cmp.Options {
+	c := debugtools.NewConfig(opts...)
+	out := cmp.Options{
+		cmp.Exporter(func(reflect.Type) bool { return true }),
+		cmp.FilterValues(special, cmp.Comparer(func(x, y interface{}) bool {
+			return deepEqual(x, y)
+		})),
+	}
+	if c.HasPathFilter() {
+		out = append(out, cmp.FilterPath(func(p cmp.Path) bool {
+			return c.Excluded(PathString(p))
+		}, cmp.Ignore()))
+	}
+	for t, canon := range c.CanonicalMapKeys() {
+		out = append(out, canonicalKeys(t, canon))
+	}
+	if tol := c.DurationTolerance(); tol > 0 {
+		out = append(out, cmp.Comparer(func(d1, d2 time.Duration) bool {
+			d := d1 - d2
+			return -tol <= d && d <= tol
+		}))
+	}
+	if n := c.Normalizer(); n != nil {
+		out = append(out, cmp.FilterValues(func(x, y interface{}) bool {
+			return reflect.ValueOf(x).Kind() == reflect.String && reflect.ValueOf(y).Kind() == reflect.String
+		}, cmp.Comparer(func(x, y interface{}) bool {
+			return n.String(reflect.ValueOf(x).String()) == n.String(reflect.ValueOf(y).String())
+		})))
+	}
+	if c.EquateNumericKinds() {
+		out = append(out, cmp.FilterValues(func(x, y interface{}) bool {
+			return x != nil && y != nil && reflect.TypeOf(x) != reflect.TypeOf(y) && isNumber(x) && isNumber(y)
+		}, cmp.Comparer(func(x, y interface{}) bool {
+			return deepEqual(x, y, debugtools.EquateNumericKinds())
+		})))
+	}
+	isChans := func(x, y interface{}) bool {
+		return reflect.ValueOf(x).Kind() == reflect.Chan && reflect.ValueOf(y).Kind() == reflect.Chan
+	}
+	switch c.ChanPolicy() {
+	case debugtools.ChanNilness:
+		out = append(out, cmp.FilterValues(isChans, cmp.Comparer(func(x, y interface{}) bool {
+			return reflect.ValueOf(x).IsNil() == reflect.ValueOf(y).IsNil()
+		})))
+	case debugtools.ChanIgnore:
+		out = append(out, cmp.FilterValues(isChans, cmp.Ignore()))
+	}
+	if r := c.Reporter(); r != nil {
+		out = append(out, Reporter(nil, r))
+	}
+	return out
+}
+
+var (
+	bigTypes = []reflect.Type{
+		reflect.TypeOf((*big.Int)(nil)),
+		reflect.TypeOf((*big.Float)(nil)),
+		reflect.TypeOf((*big.Rat)(nil)),
+	}
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+	valuerType     = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// special reports whether DeepEqual gives x and y, which have the same
+// type, special treatment by default.
+func special(x, y interface{}) bool {
+	if x == nil || y == nil {
+		return false
+	}
+	t := reflect.TypeOf(x)
+	for _, bt := range bigTypes {
+		if t == bt || t == bt.Elem() {
+			return true
+		}
+	}
+	return t == rawMessageType || t.Kind() != reflect.Ptr && t.Implements(valuerType)
+}
+
+func isNumber(x interface{}) bool {
+	switch reflect.TypeOf(x).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func deepEqual(x, y interface{}, opts ...debugtools.Option) bool {
+	eq, _ := debugtools.DeepEqual(x, y, opts...)
+	return eq
+}
+
+// canonicalKeys returns a Transformer that rekeys maps of type t by canon.
+// If two keys have the same canonical form, one of them is lost, where
+// DeepEqual would report the collision.
+func canonicalKeys(t reflect.Type, canon func(interface{}) interface{}) cmp.Option {
+	out := reflect.MapOf(reflect.TypeOf((*interface{})(nil)).Elem(), t.Elem())
+	fn := reflect.MakeFunc(reflect.FuncOf([]reflect.Type{t}, []reflect.Type{out}, false), func(args []reflect.Value) []reflect.Value {
+		m := reflect.MakeMapWithSize(out, args[0].Len())
+		iter := args[0].MapRange()
+		for iter.Next() {
+			m.SetMapIndex(reflect.ValueOf(canon(iter.Key().Interface())), iter.Value())
+		}
+		return []reflect.Value{m}
+	})
+	return cmp.Transformer("debugtools.CanonicalMapKeys", fn.Interface())
+}
+
+// PathString formats p the way debugtools writes paths, such as
+// ".Items[2].Name" or `.Headers["Accept"]`. Pointer indirections, type
+// assertions and transformations don't appear.
+func PathString(p cmp.Path) string {
+	var sb strings.Builder
+	for i, ps := range p {
+		if i > 0 {
+			sb.WriteString(pathStep(ps))
+		}
+	}
+	return sb.String()
+}
+
+func pathStep(ps cmp.PathStep) string {
+	switch s := ps.(type) {
+	case cmp.StructField:
+		return "." + s.Name()
+	case cmp.SliceIndex:
+		i := s.Key()
+		if i < 0 {
+			// The element is only on one side.
+			ix, iy := s.SplitKeys()
+			i = max(ix, iy)
+		}
+		return fmt.Sprintf("[%d]", i)
+	case cmp.MapIndex:
+		return "[" + valueString(s.Key()) + "]"
+	}
+	return ""
+}
+
+func valueString(v reflect.Value) string {
+	if !v.IsValid() {
+		return "<missing>"
+	}
+	if v.CanInterface() {
+		return fmt.Sprintf("%#v", v.Interface())
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Reporter returns a cmp Option that reports every comparison cmp makes
+// to r, with paths written as debugtools writes them, and writes each
+// mismatch to w in the form of a debugtools trace at TraceErrors. Either
+// may be nil.
+func Reporter(w io.Writer, r debugtools.Reporter) cmp.Option {
+	return cmp.Reporter(&reporter{w: w, r: r})
+}
+
+type reporter struct {
+	w    io.Writer
+	r    debugtools.Reporter
+	path cmp.Path
+	// pushed records which steps of path were passed on to r: only the
+	// root and the steps that appear in the path string are.
+	pushed []bool
+}
+
+func (rep *reporter) PushStep(ps cmp.PathStep) {
+	rep.path = append(rep.path, ps)
+	push := len(rep.path) == 1 || pathStep(ps) != ""
+	rep.pushed = append(rep.pushed, push)
+	if push && rep.r != nil {
+		rep.r.PushStep(PathString(rep.path))
+	}
+}
+
+func (rep *reporter) Report(rs cmp.Result) {
+	if rs.ByIgnore() {
+		return
+	}
+	vx, vy := rep.path.Last().Values()
+	var msg string
+	switch {
+	case !vx.IsValid():
+		msg = "Only in right: " + valueString(vy)
+	case !vy.IsValid():
+		msg = "Only in left: " + valueString(vx)
+	case rs.Equal():
+		msg = valueString(vx) + " == " + valueString(vy)
+	default:
+		msg = valueString(vx) + " != " + valueString(vy)
+	}
+	path := PathString(rep.path)
+	if !rs.Equal() && rep.w != nil {
+		if path != "" {
+			fmt.Fprintf(rep.w, "%s: ", path)
+		}
+		fmt.Fprintln(rep.w, msg)
+	}
+	if rep.r != nil {
+		rep.r.Report(debugtools.Result{Path: path, Equal: rs.Equal(), Message: msg, Left: vx, Right: vy})
+	}
+}
+
+func (rep *reporter) PopStep() {
+	push := rep.pushed[len(rep.pushed)-1]
+	rep.path = rep.path[:len(rep.path)-1]
+	rep.pushed = rep.pushed[:len(rep.pushed)-1]
+	if push && rep.r != nil {
+		rep.r.PopStep()
+	}
+}
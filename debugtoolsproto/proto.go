@@ -0,0 +1,325 @@
+// Package debugtoolsproto compares protocol buffer messages by their
+// fields as declared in the .proto file, rather than by the internals of
+// the generated Go structs, so that reports use the field and enum value
+// names of the text format and can be read without knowing Go.
+package debugtoolsproto
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	debugtools "github.com/pib/go-debugtools"
+	"github.com/pib/go-debugtools/textdiff"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Diff compares the messages a and b field by field and returns every
+// difference, like debugtools.Diff. Paths use the fields' text format
+// names, such as ".order.items[2].status"; extensions appear as
+// "[full.name]" and map entries by key. Values are formatted as in the text
+// format, on one line, with enums by value name. Repeated fields are
+// aligned before being compared, so that an inserted element is reported
+// once. IgnorePaths and OnlyPaths are honored; other options are not. Diff
+// returns an error if a and b are different message types.
+func Diff(a, b proto.Message, opts ...debugtools.Option) (*debugtools.DiffTree, error) {
+	m1, m2 := a.ProtoReflect(), b.ProtoReflect()
+	if n1, n2 := m1.Descriptor().FullName(), m2.Descriptor().FullName(); n1 != n2 {
+		return nil, fmt.Errorf("debugtoolsproto: can't compare %s with %s", n1, n2)
+	}
+	d := &differ{config: debugtools.NewConfig(opts...)}
+	return &debugtools.DiffTree{Root: d.messages("", m1, m2)}, nil
+}
+
+type differ struct {
+	config *debugtools.Config
+}
+
+func (d *differ) messages(path string, m1, m2 protoreflect.Message) *debugtools.Difference {
+	node := &debugtools.Difference{Path: path}
+	for _, fd := range fields(m1, m2) {
+		p := path + fieldStep(fd)
+		if d.config.Excluded(p) {
+			continue
+		}
+		has1, has2 := m1.Has(fd), m2.Has(fd)
+		var c *debugtools.Difference
+		switch {
+		case !has1 && !has2:
+			continue
+		case fd.IsList():
+			c = d.lists(p, fd, m1.Get(fd).List(), m2.Get(fd).List())
+		case fd.IsMap():
+			c = d.maps(p, fd, m1.Get(fd).Map(), m2.Get(fd).Map())
+		case !has1 && fd.HasPresence():
+			c = difference(p, debugtools.Inserted, fd, protoreflect.Value{}, m2.Get(fd))
+		case !has2 && fd.HasPresence():
+			c = difference(p, debugtools.Deleted, fd, m1.Get(fd), protoreflect.Value{})
+		default:
+			c = d.values(p, fd, m1.Get(fd), m2.Get(fd))
+		}
+		if c != nil {
+			node.Children = append(node.Children, c)
+		}
+	}
+	if len(node.Children) == 0 {
+		return nil
+	}
+	return node
+}
+
+// fields returns the fields declared by the messages' type, in order,
+// followed by the extensions populated in either, sorted by name.
+func fields(m1, m2 protoreflect.Message) []protoreflect.FieldDescriptor {
+	var fds []protoreflect.FieldDescriptor
+	decl := m1.Descriptor().Fields()
+	for i := 0; i < decl.Len(); i++ {
+		fds = append(fds, decl.Get(i))
+	}
+	var exts []protoreflect.FieldDescriptor
+	seen := make(map[protoreflect.FullName]bool)
+	for _, m := range []protoreflect.Message{m1, m2} {
+		m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+			if fd.IsExtension() && !seen[fd.FullName()] {
+				seen[fd.FullName()] = true
+				exts = append(exts, fd)
+			}
+			return true
+		})
+	}
+	sort.Slice(exts, func(i, j int) bool { return exts[i].FullName() < exts[j].FullName() })
+	return append(fds, exts...)
+}
+
+// values compares two singular values of field fd.
+func (d *differ) values(path string, fd protoreflect.FieldDescriptor, v1, v2 protoreflect.Value) *debugtools.Difference {
+	if fd.Message() != nil {
+		return d.messages(path, v1.Message(), v2.Message())
+	}
+	if v1.Equal(v2) {
+		return nil
+	}
+	return difference(path, debugtools.Modified, fd, v1, v2)
+}
+
+// lists compares two repeated fields, aligning their elements first.
+func (d *differ) lists(path string, fd protoreflect.FieldDescriptor, l1, l2 protoreflect.List) *debugtools.Difference {
+	node := &debugtools.Difference{Path: path}
+	add := func(c *debugtools.Difference) {
+		if c != nil {
+			node.Children = append(node.Children, c)
+		}
+	}
+	ops := textdiff.Align(l1.Len(), l2.Len(), func(i, j int) bool {
+		return l1.Get(i).Equal(l2.Get(j))
+	})
+	i, j := 0, 0
+	for k := 0; k < len(ops); {
+		if ops[k] == textdiff.Equal {
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+		var dels, ins int
+		for ; k < len(ops) && ops[k] == textdiff.Delete; k++ {
+			dels++
+		}
+		for ; k < len(ops) && ops[k] == textdiff.Insert; k++ {
+			ins++
+		}
+		// Pair up replaced elements, then report the rest as deleted or
+		// inserted.
+		for ; dels > 0 && ins > 0; dels, ins = dels-1, ins-1 {
+			if p := path + indexStep(i); !d.config.Excluded(p) {
+				add(d.values(p, fd, l1.Get(i), l2.Get(j)))
+			}
+			i, j = i+1, j+1
+		}
+		for ; dels > 0; dels-- {
+			if p := path + indexStep(i); !d.config.Excluded(p) {
+				add(difference(p, debugtools.Deleted, fd, l1.Get(i), protoreflect.Value{}))
+			}
+			i++
+		}
+		for ; ins > 0; ins-- {
+			if p := path + indexStep(j); !d.config.Excluded(p) {
+				add(difference(p, debugtools.Inserted, fd, protoreflect.Value{}, l2.Get(j)))
+			}
+			j++
+		}
+	}
+	if len(node.Children) == 0 {
+		return nil
+	}
+	return node
+}
+
+// maps compares two map fields, entry by entry in key order.
+func (d *differ) maps(path string, fd protoreflect.FieldDescriptor, m1, m2 protoreflect.Map) *debugtools.Difference {
+	var keys []protoreflect.MapKey
+	m1.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	m2.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+		if !m1.Has(k) {
+			keys = append(keys, k)
+		}
+		return true
+	})
+	sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+	vfd := fd.MapValue()
+	node := &debugtools.Difference{Path: path}
+	for _, k := range keys {
+		p := path + "[" + keyText(k) + "]"
+		if d.config.Excluded(p) {
+			continue
+		}
+		var c *debugtools.Difference
+		switch {
+		case !m1.Has(k):
+			c = difference(p, debugtools.Inserted, vfd, protoreflect.Value{}, m2.Get(k))
+		case !m2.Has(k):
+			c = difference(p, debugtools.Deleted, vfd, m1.Get(k), protoreflect.Value{})
+		default:
+			c = d.values(p, vfd, m1.Get(k), m2.Get(k))
+		}
+		if c != nil {
+			node.Children = append(node.Children, c)
+		}
+	}
+	if len(node.Children) == 0 {
+		return nil
+	}
+	return node
+}
+
+func difference(path string, kind debugtools.ChangeKind, fd protoreflect.FieldDescriptor, v1, v2 protoreflect.Value) *debugtools.Difference {
+	d := &debugtools.Difference{Path: path, Kind: kind}
+	if v1.IsValid() {
+		d.Left, d.LeftText, d.LeftType = goValue(fd, v1), valueText(fd, v1), typeName(fd)
+	}
+	if v2.IsValid() {
+		d.Right, d.RightText, d.RightType = goValue(fd, v2), valueText(fd, v2), typeName(fd)
+	}
+	switch kind {
+	case debugtools.Inserted:
+		d.Message = "Only in right: " + d.RightText
+	case debugtools.Deleted:
+		d.Message = "Only in left: " + d.LeftText
+	default:
+		d.Message = d.LeftText + " != " + d.RightText
+	}
+	return d
+}
+
+func fieldStep(fd protoreflect.FieldDescriptor) string {
+	if fd.IsExtension() {
+		return "[" + string(fd.FullName()) + "]"
+	}
+	return "." + fd.TextName()
+}
+
+func indexStep(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// valueText formats v, a value of field fd, as the text format does.
+func valueText(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return strconv.Itoa(int(v.Enum()))
+	case protoreflect.StringKind:
+		return strconv.Quote(v.String())
+	case protoreflect.BytesKind:
+		return strconv.Quote(string(v.Bytes()))
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageText(v.Message())
+	}
+	return v.String()
+}
+
+// messageText formats m on one line in the text format, such as
+// `{id:3 sku:"pen"}`, with fields in declaration order. (prototext's
+// own output deliberately varies its spacing, which would make reports
+// unstable.)
+func messageText(m protoreflect.Message) string {
+	var parts []string
+	for _, fd := range fields(m, m) {
+		if !m.Has(fd) {
+			continue
+		}
+		name := string(fd.TextName())
+		if fd.IsExtension() {
+			name = "[" + string(fd.FullName()) + "]"
+		}
+		v := m.Get(fd)
+		switch {
+		case fd.IsList():
+			elems := make([]string, v.List().Len())
+			for i := range elems {
+				elems[i] = valueText(fd, v.List().Get(i))
+			}
+			parts = append(parts, name+":["+strings.Join(elems, ", ")+"]")
+		case fd.IsMap():
+			var keys []protoreflect.MapKey
+			v.Map().Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+				keys = append(keys, k)
+				return true
+			})
+			sort.Slice(keys, func(i, j int) bool { return lessKey(keys[i], keys[j]) })
+			for _, k := range keys {
+				parts = append(parts, name+":{key:"+keyText(k)+" value:"+valueText(fd.MapValue(), v.Map().Get(k))+"}")
+			}
+		default:
+			parts = append(parts, name+":"+valueText(fd, v))
+		}
+	}
+	return "{" + strings.Join(parts, " ") + "}"
+}
+
+// goValue returns v as a Go value: messages as proto.Messages, enums as
+// their value names and other values as their Go types.
+func goValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		return valueText(fd, v)
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return v.Message().Interface()
+	}
+	return v.Interface()
+}
+
+func typeName(fd protoreflect.FieldDescriptor) string {
+	switch fd.Kind() {
+	case protoreflect.EnumKind:
+		return string(fd.Enum().FullName())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return string(fd.Message().FullName())
+	}
+	return fd.Kind().String()
+}
+
+func keyText(k protoreflect.MapKey) string {
+	if s, ok := k.Interface().(string); ok {
+		return strconv.Quote(s)
+	}
+	return k.String()
+}
+
+// lessKey orders map keys, which all have the same kind.
+func lessKey(k1, k2 protoreflect.MapKey) bool {
+	switch x := k1.Interface().(type) {
+	case bool:
+		return !x && k2.Bool()
+	case int32, int64:
+		return k1.Int() < k2.Int()
+	case uint32, uint64:
+		return k1.Uint() < k2.Uint()
+	}
+	return k1.String() < k2.String()
+}
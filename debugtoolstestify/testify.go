@@ -0,0 +1,114 @@
+// Package debugtoolstestify brings debugtools comparisons to testify
+// suites: its assertions have the same shape as testify's, so existing
+// assertions can be switched over by changing an import, but they compare
+// with debugtools and report every difference on failure instead of
+// testify's dump of both values.
+package debugtoolstestify
+
+import (
+	"bytes"
+	"fmt"
+
+	debugtools "github.com/pib/go-debugtools"
+	"github.com/stretchr/testify/assert"
+)
+
+// An Asserter makes assertions comparing with a set of debugtools Options.
+type Asserter struct {
+	opts []debugtools.Option
+}
+
+// New returns an Asserter comparing with opts.
+func New(opts ...debugtools.Option) *Asserter {
+	return &Asserter{opts: opts}
+}
+
+var defaultAsserter = New()
+
+// ObjectsAreEqual reports whether expected and actual are equal. Like
+// assert.ObjectsAreEqual, it compares []byte values with bytes.Equal, so
+// nil and empty slices are equal; everything else is compared with
+// debugtools.DeepEqual.
+func ObjectsAreEqual(expected, actual interface{}) bool {
+	return defaultAsserter.ObjectsAreEqual(expected, actual)
+}
+
+// Equal asserts that expected and actual are equal, as assert.Equal does,
+// using ObjectsAreEqual.
+func Equal(t assert.TestingT, expected, actual interface{}, msgAndArgs ...interface{}) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	return defaultAsserter.Equal(t, expected, actual, msgAndArgs...)
+}
+
+// NotEqual asserts that expected and actual are not equal, as
+// assert.NotEqual does, using ObjectsAreEqual.
+func NotEqual(t assert.TestingT, expected, actual interface{}, msgAndArgs ...interface{}) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	return defaultAsserter.NotEqual(t, expected, actual, msgAndArgs...)
+}
+
+// Comparison returns an assert.Comparison for use with assert.Condition
+// that reports whether expected and actual are equal.
+func Comparison(expected, actual interface{}) assert.Comparison {
+	return defaultAsserter.Comparison(expected, actual)
+}
+
+// ObjectsAreEqual is like the package-level ObjectsAreEqual, but compares
+// with a's Options.
+func (a *Asserter) ObjectsAreEqual(expected, actual interface{}) bool {
+	eb, ok1 := expected.([]byte)
+	ab, ok2 := actual.([]byte)
+	if ok1 && ok2 {
+		return bytes.Equal(eb, ab)
+	}
+	eq, _ := debugtools.DeepEqual(expected, actual, a.opts...)
+	return eq
+}
+
+// Equal is like the package-level Equal, but compares with a's Options.
+func (a *Asserter) Equal(t assert.TestingT, expected, actual interface{}, msgAndArgs ...interface{}) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	if a.ObjectsAreEqual(expected, actual) {
+		return true
+	}
+	return assert.Fail(t, "Not equal:\n"+a.describe(expected, actual), msgAndArgs...)
+}
+
+// NotEqual is like the package-level NotEqual, but compares with a's
+// Options.
+func (a *Asserter) NotEqual(t assert.TestingT, expected, actual interface{}, msgAndArgs ...interface{}) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	if !a.ObjectsAreEqual(expected, actual) {
+		return true
+	}
+	return assert.Fail(t, fmt.Sprintf("Should not be: %#v", actual), msgAndArgs...)
+}
+
+// Comparison is like the package-level Comparison, but compares with a's
+// Options.
+func (a *Asserter) Comparison(expected, actual interface{}) assert.Comparison {
+	return func() bool {
+		return a.ObjectsAreEqual(expected, actual)
+	}
+}
+
+// describe explains how expected and actual differ, listing every
+// difference found by debugtools.Diff.
+func (a *Asserter) describe(expected, actual interface{}) string {
+	buf := &bytes.Buffer{}
+	buf.WriteString("Differences:\n")
+	if d, err := debugtools.Diff(expected, actual, a.opts...); err == nil {
+		buf.WriteString(d.String())
+	} else {
+		fmt.Fprintf(buf, "Types don't match: %T != %T\n", expected, actual)
+	}
+	return buf.String()
+}
@@ -1,11 +1,13 @@
 package debugtools
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"reflect"
+	"strconv"
 	"strings"
+
+	"github.com/pib/go-debugtools/textdiff"
 )
 
 // Derived from reflect.DeepEqual
@@ -19,7 +21,7 @@ import (
 // During deepValueEqual, must keep track of checks that are
 // in progress.  The comparison algorithm assumes that all
 // checks in progress are true when it reencounters them.
-// Visited comparisons are stored in a map indexed by visit.
+// Visited comparisons are stored in a visitSet.
 type visit struct {
 	a1  uintptr
 	a2  uintptr
@@ -27,30 +29,123 @@ type visit struct {
 }
 
 type deepEqualState struct {
-	visited map[visit]bool
+	visited visitSet
 	depth   int
 	sub     bool
 	w       io.Writer
+	opts    *options
+	path    []string
+	// stack holds the frames of the values being compared, innermost
+	// last.
+	stack []frame
+	// asJSON is set when the next values compared are from a []byte
+	// field tagged `deepequal:"json"`.
+	asJSON bool
+	// inJSON is set while comparing documents decoded by jsonEqual, whose
+	// numbers are compared by value.
+	inJSON bool
+	// full is set to carry on past the first mismatch, as Diff does.
+	full bool
+	// v1 and v2 are the values currently being compared.
+	v1, v2 reflect.Value
+	// paths is set when the path of the values being compared is needed:
+	// for the trace, a Reporter, Diff or a path filter. Otherwise path is
+	// left empty, so that comparing equal values builds no strings.
+	paths bool
+	// pool is the pool the state came from, and buf the buffer the trace
+	// is written to, kept with the state for reuse.
+	pool *statePool
+	buf  *cappedBuffer
+	// line holds each write to the trace as it is put together, so that
+	// lines are written whole.
+	line []byte
+}
+
+// observed reports whether anything but the result of the comparison is
+// wanted: a trace or a Reporter. If not, messages needn't be formatted.
+func (s *deepEqualState) observed() bool {
+	return s.w != nil || s.opts.reporter != nil
+}
+
+// tracing reports whether println and printf write to the trace, so that
+// callers can skip preparing what they would discard.
+func (s *deepEqualState) tracing() bool {
+	return s.w != nil && s.opts.level != TraceErrors
 }
 
 func (s *deepEqualState) println(vals ...interface{}) {
-	if s.sub {
-		s.sub = false
-	} else if s.depth > 0 {
-		fmt.Fprint(s.w, strings.Repeat("  ", s.depth))
+	if !s.tracing() {
+		return
 	}
-	fmt.Fprintln(s.w, vals...)
+	s.line = fmt.Appendln(s.startLine(), vals...)
+	s.w.Write(s.line)
 }
 
 func (s *deepEqualState) printf(format string, vals ...interface{}) {
+	if !s.tracing() {
+		return
+	}
+	s.line = fmt.Appendf(s.startLine(), format, vals...)
+	s.w.Write(s.line)
+}
+
+// trace writes parts to the trace, as printf would with them concatenated
+// as its format, but without fmt, for the lines written at every value.
+func (s *deepEqualState) trace(parts ...string) {
+	if !s.tracing() {
+		return
+	}
+	b := s.startLine()
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	s.line = b
+	s.w.Write(b)
+}
+
+// traceType writes a line of the trace naming the type t after prefix,
+// only formatting the type if the trace is being written.
+func (s *deepEqualState) traceType(prefix string, t reflect.Type) {
+	if s.tracing() {
+		s.trace(prefix, t.String(), "\n")
+	}
+}
+
+// traceLeaf writes the line of the trace comparing the leaves v1 and v2.
+func (s *deepEqualState) traceLeaf(v1, v2 reflect.Value, eq bool) {
+	op := " != "
+	if eq {
+		op = " == "
+	}
+	b := appendValue(s.startLine(), v1)
+	b = appendValue(append(b, op...), v2)
+	s.line = append(b, '\n')
+	s.w.Write(s.line)
+}
+
+// startLine returns the buffer for the next write to the trace, holding
+// its indentation, unless the write continues a line begun by the one
+// before.
+func (s *deepEqualState) startLine() []byte {
+	b := s.line[:0]
 	if s.sub {
 		s.sub = false
-	} else if s.depth > 0 {
-		fmt.Fprint(s.w, strings.Repeat("  ", s.depth))
+		return b
+	}
+	if s.buf != nil && s.buf.full {
+		// The line is only counted, so it needn't be indented, which
+		// would otherwise cost as much as the depth of every line.
+		return b
+	}
+	for n := 2 * s.depth; n > 0; n -= len(indent) {
+		b = append(b, indent[:min(n, len(indent))]...)
 	}
-	fmt.Fprintf(s.w, format, vals...)
+	return b
 }
 
+// indent is the indentation of the trace, sliced to the depth of a line.
+const indent = "                                                                "
+
 func (s *deepEqualState) incDepth() {
 	s.depth++
 }
@@ -59,32 +154,225 @@ func (s *deepEqualState) decDepth() {
 	s.depth--
 }
 
-// Tests for deep equality using reflected types. The map argument tracks
-// comparisons that have already been seen, which allows short circuiting on
-// recursive types.
+// pushStep records that the comparison is descending into a child of the
+// current values, such as a struct field or slice element.
+func (s *deepEqualState) pushStep(step string) {
+	if !s.paths {
+		return
+	}
+	s.path = append(s.path, step)
+	if s.opts.reporter != nil {
+		s.opts.reporter.PushStep(s.currentPath())
+	}
+}
+
+func (s *deepEqualState) popStep() {
+	if !s.paths {
+		return
+	}
+	s.path = s.path[:len(s.path)-1]
+	if s.opts.reporter != nil {
+		s.opts.reporter.PopStep()
+	}
+}
+
+func (s *deepEqualState) currentPath() string {
+	return strings.Join(s.path, "")
+}
+
+// skip reports whether the child at step is excluded from the comparison.
+func (s *deepEqualState) skip(step string) bool {
+	if len(s.opts.ignore) == 0 && len(s.opts.only) == 0 {
+		return false
+	}
+	return s.opts.excluded(s.currentPath() + step)
+}
+
+// report passes the outcome of comparing the values at the current path to
+// the Reporter, if there is one, and returns equal. At TraceErrors, this is
+// also where mismatches are written to the trace.
+func (s *deepEqualState) report(equal bool, format string, vals ...interface{}) bool {
+	if !s.observed() {
+		return equal
+	}
+	if !equal && s.w != nil && s.opts.level == TraceErrors {
+		if s.opts.mismatchTemplate != nil {
+			s.writeMismatch(fmt.Sprintf(format, vals...))
+		} else {
+			if path := s.currentPath(); path != "" {
+				fmt.Fprintf(s.w, "%s: ", path)
+			}
+			fmt.Fprintf(s.w, format+"\n", vals...)
+		}
+	}
+	if s.opts.reporter != nil {
+		s.opts.reporter.Report(Result{
+			Path:    s.currentPath(),
+			Equal:   equal,
+			Message: fmt.Sprintf(format, vals...),
+			Left:    s.v1,
+			Right:   s.v2,
+		})
+	}
+	return equal
+}
+
+// onlyOnOneSide reports the child at step, which is present in v1 but not
+// v2 or the other way around.
+func (s *deepEqualState) onlyOnOneSide(step string, v1, v2 reflect.Value) {
+	s.pushStep(step)
+	prev1, prev2 := s.v1, s.v2
+	s.v1, s.v2 = v1, v2
+	if v1.IsValid() {
+		s.printf("  %s: Only in left: %v\n", step, anyString(v1))
+		s.report(false, "Only in left: %v", anyString(v1))
+	} else {
+		s.printf("  %s: Only in right: %v\n", step, anyString(v2))
+		s.report(false, "Only in right: %v", anyString(v2))
+	}
+	s.v1, s.v2 = prev1, prev2
+	s.popStep()
+}
+
+// A frame is a value being compared whose children are still to be
+// compared: the elements of an array or slice, the fields of a struct, the
+// entries of a map, or what a pointer or interface holds. deepValueEqual
+// keeps them on a stack rather than recursing, so that the depth of the
+// values compared isn't limited by the goroutine stack.
+type frame struct {
+	v1, v2 reflect.Value
+	// fields lists the fields of a struct.
+	fields []fieldPlan
+	// next is the index of the next child to compare.
+	next int
+	// equal is cleared once a child compares unequal, and done set once
+	// the rest of the children needn't be compared.
+	equal, done bool
+	// stepped is set while a child is compared under a step pushed onto
+	// the path.
+	stepped bool
+	// keys is set for a map.
+	keys *frameKeys
+	// aligned is set for slices compared aligned, as the pairs from
+	// alignSlices.
+	aligned bool
+	pairs   []alignedPair
+}
+
+// frameKeys holds the keys of a map being compared, and the values they map
+// to on the left, along with the keys of both maps by their canonical form,
+// if CanonicalMapKeys applies. The values are read as the keys are, since
+// MapIndex never finds a NaN key.
+type frameKeys struct {
+	keys, vals   []reflect.Value
+	canon        func(interface{}) interface{}
+	keys1, keys2 map[interface{}]reflect.Value
+}
+
+// An alignedPair is a step of comparing two slices aligned: the i'th element
+// of the first compared with the j'th of the second, or, if one of i and j
+// is -1, an element found only on the other side.
+type alignedPair struct {
+	i, j int
+}
+
+// Tests for deep equality using reflected types. Comparisons that have
+// already been seen are tracked in the visited set, which allows short
+// circuiting on recursive types.
+//
+// The values are walked with the stack of frames in s rather than by
+// recursion. A call made while comparing, as jsonEqual makes, walks its
+// values on top of the frames already there.
 func (s *deepEqualState) deepValueEqual(v1, v2 reflect.Value) bool {
+	prev1, prev2 := s.v1, s.v2
+	base := len(s.stack)
+	eq, pushed := s.descend(v1, v2)
+	for len(s.stack) > base {
+		f := &s.stack[len(s.stack)-1]
+		if !pushed {
+			// eq is the result of comparing the last child of f.
+			s.v1, s.v2 = f.v1, f.v2
+			if f.stepped {
+				s.popStep()
+				f.stepped = false
+			}
+			if !eq {
+				f.equal = false
+				f.done = !s.full
+			}
+		}
+		if c1, c2, ok := s.nextChild(f); ok {
+			eq, pushed = s.descend(c1, c2)
+			continue
+		}
+		eq, pushed = s.leave(f), false
+	}
+	s.v1, s.v2 = prev1, prev2
+	return eq
+}
+
+// descend starts comparing v1 and v2, a level deeper than the values
+// before. It reports whether it pushed a frame for their children, in which
+// case eq is meaningless until the frame is left.
+func (s *deepEqualState) descend(v1, v2 reflect.Value) (eq, pushed bool) {
 	s.incDepth()
-	defer s.decDepth()
+	n := len(s.stack)
+	eq = s.enter(v1, v2)
+	if pushed = len(s.stack) > n; !pushed {
+		s.decDepth()
+	}
+	return eq, pushed
+}
+
+// push pushes f, whose children are compared next. It returns false for
+// enter to return, which is meaningless once a frame is pushed.
+func (s *deepEqualState) push(f frame) bool {
+	s.stack = append(s.stack, f)
+	return false
+}
+
+// leave pops f, the frame on top of the stack, once its children have all
+// been compared, and returns the result of comparing its values.
+func (s *deepEqualState) leave(f *frame) bool {
+	equal := f.equal
+	if f.keys != nil && s.full && !equal {
+		s.onlyInRight(f)
+	}
+	s.stack[len(s.stack)-1] = frame{}
+	s.stack = s.stack[:len(s.stack)-1]
+	s.decDepth()
+	return equal
+}
+
+// enter compares v1 and v2 if they can be compared outright. Otherwise it
+// pushes a frame for their children, which deepValueEqual compares in
+// turn.
+func (s *deepEqualState) enter(v1, v2 reflect.Value) bool {
+	s.v1, s.v2 = v1, v2
+	if s.opts.observe != nil {
+		s.opts.observe(v1, v2)
+	}
 
 	if !v1.IsValid() || !v2.IsValid() {
 		s.println("Something is not valid:", v1, v2)
-		return v1.IsValid() == v2.IsValid()
+		return s.report(v1.IsValid() == v2.IsValid(), "Something is not valid: %v %v", v1, v2)
 	}
 	if v1.Type() != v2.Type() {
-		s.printf("Types don't match: %v (%s) != %v (%s)", v1.Interface(), v1.Type(), v2.Interface(), v2.Type())
-		return false
+		if s.opts.equateNumeric && numericClass(v1.Kind()) != notNumeric && numericClass(v2.Kind()) != notNumeric {
+			return s.numericEqual(v1, v2)
+		}
+		s.printf("Types don't match: %s (%s) != %s (%s)", anyString(v1), v1.Type(), anyString(v2), v2.Type())
+		return s.report(false, "Types don't match: %s != %s", v1.Type(), v2.Type())
 	}
 
-	// if depth > 10 { panic("deepValueEqual") }	// for debugging
-	hard := func(k reflect.Kind) bool {
-		switch k {
-		case reflect.Array, reflect.Map, reflect.Slice, reflect.Struct:
-			return true
-		}
-		return false
+	plan := s.opts.planFor(v1.Type())
+	if plan.flat && !s.observed() {
+		// Nothing is listening for the fields, so the struct is compared
+		// whole. If it differs, the trace is written by walking it again.
+		return flatEqual(v1, v2, plan.raw)
 	}
 
-	if v1.CanAddr() && v2.CanAddr() && hard(v1.Kind()) {
+	if plan.hard && v1.CanAddr() && v2.CanAddr() {
 		addr1 := v1.UnsafeAddr()
 		addr2 := v2.UnsafeAddr()
 		if addr1 > addr2 {
@@ -93,130 +381,443 @@ func (s *deepEqualState) deepValueEqual(v1, v2 reflect.Value) bool {
 		}
 
 		// Short circuit if references are identical ...
-		if addr1 == addr2 {
-			s.println("  Same address, so equal")
-			return true
+		if addr1 == addr2 && s.opts.level < TraceVerbose {
+			s.trace("  Same address, so equal\n")
+			return s.report(true, "Same address, so equal")
 		}
 
 		// ... or already seen
 		typ := v1.Type()
 		v := visit{addr1, addr2, typ}
-		if s.visited[v] {
-			s.println("  Already visited, so equal")
-			return true
+		if s.visited.has(v) {
+			s.trace("  Already visited, so equal\n")
+			return s.report(true, "Already visited, so equal")
 		}
 
 		// Remember for later.
-		s.visited[v] = true
+		s.visited.add(v)
+	}
+
+	if plan.special || s.asJSON {
+		if eq, ok := s.specialEqual(v1, v2, plan); ok {
+			return eq
+		}
 	}
 
 	switch v1.Kind() {
 	case reflect.Array:
-		s.println("Comparing arrays of type:", v1.Type())
-		for i := 0; i < v1.Len(); i++ {
-			if !s.deepValueEqual(v1.Index(i), v2.Index(i)) {
-				return false
-			}
-		}
-		return true
+		s.traceType("Comparing arrays of type: ", v1.Type())
+		return s.push(frame{v1: v1, v2: v2, equal: true})
 	case reflect.Slice:
-		s.println("Comparing slices of type:", v1.Type())
+		s.traceType("Comparing slices of type: ", v1.Type())
 		if v1.IsNil() != v2.IsNil() {
-			s.printf("  %#v != %#v\n", v1.Interface(), v2.Interface())
-			s.println("  One of the slices is nil, so not equal")
-			return false
+			if s.tracing() {
+				s.trace("  ", anyString(v1), " != ", anyString(v2), "\n")
+			}
+			s.trace("  One of the slices is nil, so not equal\n")
+			return s.report(false, "One of the slices is nil, so not equal")
 		}
 		if v1.Len() != v2.Len() {
-			s.println("  Unequal lengths, so not equal")
-			return false
+			s.trace("  Unequal lengths, so not equal\n")
+			if !s.full {
+				return s.report(false, "Unequal lengths, so not equal")
+			}
 		}
-		if v1.Pointer() == v2.Pointer() {
-			s.println("  Pointers equal, so equal")
-			return true
+		if v1.Pointer() == v2.Pointer() && v1.Len() == v2.Len() && s.opts.level < TraceVerbose {
+			s.trace("  Pointers equal, so equal\n")
+			return s.report(true, "Pointers equal, so equal")
 		}
-		for i := 0; i < v1.Len(); i++ {
-			if !s.deepValueEqual(v1.Index(i), v2.Index(i)) {
-				return false
-			}
+		if s.full {
+			pairs := s.alignSlices(v1, v2)
+			return s.push(frame{v1: v1, v2: v2, aligned: true, pairs: pairs, equal: len(pairs) == 0})
 		}
-		return true
+		return s.push(frame{v1: v1, v2: v2, equal: true})
 	case reflect.Interface:
-		s.println("Comparing interfaces of type:", v1.Type())
+		if !s.tracing() {
+			// Skip formatting the types.
+		} else if t1, t2 := interfaceTypeString(v1), interfaceTypeString(v2); t1 == t2 {
+			s.trace("Comparing interfaces of type: ", t1, "\n")
+		} else {
+			s.trace("Comparing interfaces of type: ", t1, " and ", t2, "\n")
+		}
 		if v1.IsNil() || v2.IsNil() {
-			s.println("  One of the interfaces is nil, so not equal")
-			return v1.IsNil() == v2.IsNil()
+			s.trace("  One of the interfaces is nil, so not equal\n")
+			return s.report(v1.IsNil() == v2.IsNil(), "One of the interfaces is nil")
 		}
-		return s.deepValueEqual(v1.Elem(), v2.Elem())
+		return s.push(frame{v1: v1, v2: v2, equal: true})
 	case reflect.Ptr:
-		s.println("Comparing pointers of type:", v1.Type())
-		return s.deepValueEqual(v1.Elem(), v2.Elem())
-	case reflect.Struct:
-		s.println("Comparing structs of type:", v1.Type())
-		for i, n := 0, v1.NumField(); i < n; i++ {
-			s.printf("  %v: ", v1.Type().Field(i).Name)
-			s.sub = true
-			if !s.deepValueEqual(v1.Field(i), v2.Field(i)) {
-				return false
+		s.traceType("Comparing pointers of type: ", v1.Type())
+		if v1.IsNil() != v2.IsNil() {
+			s.trace("  One of the pointers is nil, so not equal\n")
+			// The pointer is still there, so this is a change of value
+			// rather than of structure: report the nil pointer against
+			// what the other one points to.
+			if v1.IsNil() {
+				s.v2 = v2.Elem()
+			} else {
+				s.v1 = v1.Elem()
 			}
+			return s.report(false, "One of the pointers is nil, so not equal")
 		}
-		return true
+		return s.push(frame{v1: v1, v2: v2, equal: true})
+	case reflect.Struct:
+		s.traceType("Comparing structs of type: ", v1.Type())
+		return s.push(frame{v1: v1, v2: v2, fields: plan.fields, equal: true})
 	case reflect.Map:
-		s.println("Comparing map of type:", v1.Type())
+		s.traceType("Comparing map of type: ", v1.Type())
 		if v1.IsNil() != v2.IsNil() {
-			s.println("  One of the maps is nil, so not equal")
-			return false
+			s.trace("  One of the maps is nil, so not equal\n")
+			return s.report(false, "One of the maps is nil, so not equal")
 		}
-		if v1.Len() != v2.Len() {
-			s.println("  Lengths don't match, so not equal")
-			return false
+		equal := v1.Len() == v2.Len()
+		if !equal {
+			s.trace("  Lengths don't match, so not equal\n")
+			if !s.full {
+				return s.report(false, "Lengths don't match, so not equal")
+			}
 		}
-		if v1.Pointer() == v2.Pointer() {
-			s.println("  Same pointer, so equal")
-			return true
+		if v1.Pointer() == v2.Pointer() && s.opts.level < TraceVerbose {
+			s.trace("  Same pointer, so equal\n")
+			return s.report(true, "Same pointer, so equal")
 		}
-		for _, k := range v1.MapKeys() {
-			s.printf("  %#v: ", k.Interface())
-			s.sub = true
-			if !s.deepValueEqual(v1.MapIndex(k), v2.MapIndex(k)) {
+		keys := &frameKeys{canon: plan.canon}
+		if keys.canon != nil {
+			var ok bool
+			if keys.keys1, ok = s.canonicalKeys(v1, keys.canon); !ok {
+				return false
+			}
+			if keys.keys2, ok = s.canonicalKeys(v2, keys.canon); !ok {
 				return false
 			}
 		}
-		return true
+		keys.keys, keys.vals = mapKeysValues(v1)
+		return s.push(frame{v1: v1, v2: v2, keys: keys, equal: equal})
+	case reflect.Chan:
+		return s.chanEqual(v1, v2)
 	case reflect.Func:
 		if v1.IsNil() && v2.IsNil() {
-			s.println("  Both nil functions, so equal")
-			return true
+			s.trace("  Both nil functions, so equal\n")
+			return s.report(true, "Both nil functions, so equal")
 		}
 		// Can't do better than this:
-		s.println("  Not both nil functions, so not equal")
-		return false
+		s.trace("  Not both nil functions, so not equal\n")
+		return s.report(false, "Not both nil functions, so not equal")
 
 	default:
-		// Normal equality suffices
-		if v1.CanInterface() && v2.CanInterface() {
-			if eq := reflect.DeepEqual(v1.Interface(), v2.Interface()); eq {
-				s.printf("%#v == %#v\n", v1.Interface(), v2.Interface())
-				return true
+		// Normal equality suffices. The values are only formatted if
+		// something is listening.
+		eq := leafEqual(v1, v2)
+		if !s.observed() {
+			return eq
+		}
+		if s.opts.reporter == nil && s.tracing() {
+			// Only the trace wants the values, so they are appended to it
+			// directly rather than formatted as strings first.
+			s.traceLeaf(v1, v2, eq)
+			return eq
+		}
+		s1, s2 := anyString(v1), anyString(v2)
+		if eq {
+			s.trace(s1, " == ", s2, "\n")
+			return s.report(true, "%s == %s", s1, s2)
+		}
+		s.trace(s1, " != ", s2, "\n")
+		return s.report(false, "%s != %s", s1, s2)
+	}
+}
+
+// nextChild moves f on to its next child to compare, pushing its step onto
+// the path, and returns the child's values. It reports false once there
+// are none left.
+func (s *deepEqualState) nextChild(f *frame) (c1, c2 reflect.Value, ok bool) {
+	if f.done {
+		return c1, c2, false
+	}
+	v1, v2 := f.v1, f.v2
+	switch {
+	case f.aligned:
+		return s.nextPair(f)
+	case f.keys != nil:
+		return s.nextEntry(f)
+	}
+	switch v1.Kind() {
+	case reflect.Array, reflect.Slice:
+		for ; f.next < v1.Len(); f.next++ {
+			step := elemStep(f.next)
+			if s.skip(step) {
+				continue
+			}
+			s.pushStep(step)
+			f.stepped = true
+			i := f.next
+			f.next++
+			return v1.Index(i), v2.Index(i), true
+		}
+	case reflect.Interface, reflect.Ptr:
+		if f.next == 0 {
+			f.next++
+			return v1.Elem(), v2.Elem(), true
+		}
+	case reflect.Struct:
+		for ; f.next < len(f.fields); f.next++ {
+			field := &f.fields[f.next]
+			var step string
+			if s.paths {
+				if step = field.step; s.skip(step) {
+					continue
+				}
+			}
+			if s.tracing() {
+				s.trace("  ", field.name, ": ")
+				s.sub = true
+			}
+			s.asJSON = field.asJSON
+			s.pushStep(step)
+			f.stepped = true
+			i := f.next
+			f.next++
+			return v1.Field(i), v2.Field(i), true
+		}
+	}
+	return c1, c2, false
+}
+
+// nextEntry is nextChild for a map, returning the values of its next key
+// that is in both maps. Keys only in the left one are reported on the way.
+func (s *deepEqualState) nextEntry(f *frame) (c1, c2 reflect.Value, ok bool) {
+	v2, keys := f.v2, f.keys
+	for ; f.next < len(keys.keys); f.next++ {
+		k := keys.keys[f.next]
+		var step string
+		if s.paths {
+			if step = "[" + anyString(k) + "]"; s.skip(step) {
+				continue
+			}
+		}
+		k2 := k
+		if keys.canon != nil {
+			c := keys.canon(k.Interface())
+			var ok bool
+			if k2, ok = keys.keys2[c]; !ok {
+				s.onlyOnOneSide(step, keys.vals[f.next], reflect.Value{})
+				f.equal = false
+				if !s.full {
+					f.done = true
+					return c1, c2, false
+				}
+				continue
+			}
+			if !s.tracing() {
+				// Skip formatting the keys.
+			} else if k1s, k2s := anyString(k), anyString(k2); k1s != k2s {
+				s.trace("  ", k1s, " ~ ", k2s, ": ")
 			} else {
-				s.printf("%#v != %#v\n", v1.Interface(), v2.Interface())
-				return false
+				s.trace("  ", k1s, ": ")
 			}
 		} else {
-			s1, s2 := anyString(v1), anyString(v2)
-			if s1 == s2 {
-				s.printf("%v == %v\n", s1, s2)
-				return true
-			} else {
-				s.printf("%v != %v\n", s1, s2)
-				return false
+			if s.full && !v2.MapIndex(k).IsValid() {
+				s.onlyOnOneSide(step, keys.vals[f.next], reflect.Value{})
+				f.equal = false
+				continue
+			}
+			if s.tracing() {
+				s.trace("  ", anyString(k), ": ")
+			}
+		}
+		s.sub = s.tracing()
+		s.pushStep(step)
+		f.stepped = true
+		f.next++
+		return keys.vals[f.next-1], v2.MapIndex(k2), true
+	}
+	return c1, c2, false
+}
+
+// onlyInRight reports the keys of the map of f that are only in its right
+// side.
+func (s *deepEqualState) onlyInRight(f *frame) {
+	v1, v2, canon := f.v1, f.v2, f.keys.canon
+	for iter := v2.MapRange(); iter.Next(); {
+		k := iter.Key()
+		step := "[" + anyString(k) + "]"
+		if s.skip(step) {
+			continue
+		}
+		if canon != nil {
+			if f.keys.keys1[canon(k.Interface())].IsValid() {
+				continue
 			}
+		} else if v1.MapIndex(k).IsValid() {
+			continue
+		}
+		s.onlyOnOneSide(step, reflect.Value{}, iter.Value())
+	}
+}
 
+// leafEqual compares two values of a basic kind with ==, reading them
+// with the accessor for their kind rather than through Interface, which
+// would allocate, and panic for values of unexported fields.
+func leafEqual(v1, v2 reflect.Value) bool {
+	switch v1.Kind() {
+	case reflect.Bool:
+		return v1.Bool() == v2.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v1.Int() == v2.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v1.Uint() == v2.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v1.Float() == v2.Float()
+	case reflect.Complex64, reflect.Complex128:
+		return v1.Complex() == v2.Complex()
+	case reflect.String:
+		return v1.String() == v2.String()
+	case reflect.UnsafePointer:
+		return v1.Pointer() == v2.Pointer()
+	}
+	return anyString(v1) == anyString(v2)
+}
+
+// interfaceTypeString returns the type of the interface value v, followed
+// by its dynamic type if it isn't nil, as in error(*fs.PathError).
+func interfaceTypeString(v reflect.Value) string {
+	if v.IsNil() {
+		return v.Type().String()
+	}
+	return v.Type().String() + "(" + v.Elem().Type().String() + ")"
+}
+
+// mapKeysValues returns the keys of the map m and the values they map to.
+func mapKeysValues(m reflect.Value) (keys, vals []reflect.Value) {
+	keys = make([]reflect.Value, 0, m.Len())
+	vals = make([]reflect.Value, 0, m.Len())
+	for iter := m.MapRange(); iter.Next(); {
+		keys = append(keys, iter.Key())
+		vals = append(vals, iter.Value())
+	}
+	return keys, vals
+}
+
+// canonicalKeys indexes the keys of m by their canonical form. It reports
+// false if two keys share a canonical form, since they could then not be
+// matched up with the keys of the other map.
+func (s *deepEqualState) canonicalKeys(m reflect.Value, canon func(interface{}) interface{}) (map[interface{}]reflect.Value, bool) {
+	keys := make(map[interface{}]reflect.Value, m.Len())
+	for _, k := range m.MapKeys() {
+		c := canon(k.Interface())
+		if prev, ok := keys[c]; ok {
+			s.printf("  Keys %#v and %#v have the same canonical form, so not equal\n", prev.Interface(), k.Interface())
+			return nil, s.report(false, "Keys %#v and %#v have the same canonical form, so not equal", prev.Interface(), k.Interface())
 		}
+		keys[c] = k
 	}
+	return keys, true
+}
+
+// alignSlices aligns the elements of two slices the way a text diff aligns
+// lines, so that an element inserted into or deleted from the middle of a
+// slice is reported once, rather than as a mismatch at every later index.
+// Runs of deleted elements directly followed by inserted ones are paired
+// up, to be compared as modifications. It returns the pairs, and the
+// elements only on one side, in order, leaving out those that are equal.
+func (s *deepEqualState) alignSlices(v1, v2 reflect.Value) []alignedPair {
+	ops := textdiff.Align(v1.Len(), v2.Len(), func(i, j int) bool {
+		return s.quietEqual(v1.Index(i), v2.Index(j), elemStep(i))
+	})
+	var pairs []alignedPair
+	i, j := 0, 0
+	for k := 0; k < len(ops); {
+		if ops[k] == textdiff.Equal {
+			i, j, k = i+1, j+1, k+1
+			continue
+		}
+		var dels, ins int
+		for k < len(ops) && ops[k] == textdiff.Delete {
+			dels, k = dels+1, k+1
+		}
+		for k < len(ops) && ops[k] == textdiff.Insert {
+			ins, k = ins+1, k+1
+		}
+		for n := min(dels, ins); n > 0; n-- {
+			pairs = append(pairs, alignedPair{i, j})
+			i, j, dels, ins = i+1, j+1, dels-1, ins-1
+		}
+		for ; dels > 0; dels-- {
+			pairs = append(pairs, alignedPair{i, -1})
+			i++
+		}
+		for ; ins > 0; ins-- {
+			pairs = append(pairs, alignedPair{-1, j})
+			j++
+		}
+	}
+	return pairs
+}
+
+// nextPair is nextChild for slices compared aligned, returning the
+// elements of the next pair. Elements only on one side are reported on
+// the way.
+func (s *deepEqualState) nextPair(f *frame) (c1, c2 reflect.Value, ok bool) {
+	for f.next < len(f.pairs) {
+		p := f.pairs[f.next]
+		f.next++
+		switch {
+		case p.j < 0:
+			if step := elemStep(p.i); !s.skip(step) {
+				s.onlyOnOneSide(step, f.v1.Index(p.i), reflect.Value{})
+			}
+		case p.i < 0:
+			if step := elemStep(p.j); !s.skip(step) {
+				s.onlyOnOneSide(step, reflect.Value{}, f.v2.Index(p.j))
+			}
+		default:
+			step := elemStep(p.i)
+			if s.skip(step) {
+				continue
+			}
+			s.pushStep(step)
+			f.stepped = true
+			return f.v1.Index(p.i), f.v2.Index(p.j), true
+		}
+	}
+	return c1, c2, false
+}
+
+// quietEqual reports whether v1 and v2, found at step below the current
+// path, are equal, without writing to the trace or the Reporter.
+func (s *deepEqualState) quietEqual(v1, v2 reflect.Value, step string) bool {
+	o := *s.opts
+	o.reporter, o.observe = nil, nil
+	q := s.pool.get(&o)
+	defer s.pool.put(q)
+	q.depth = s.depth
+	q.path = append(append(q.path, s.path...), step)
+	q.paths = s.paths
+	return q.deepValueEqual(v1, v2)
+}
+
+// elemSteps holds the steps for the first indexes, so that comparing the
+// elements of short slices and arrays doesn't format their indexes.
+var elemSteps = func() []string {
+	steps := make([]string, 256)
+	for i := range steps {
+		steps[i] = "[" + strconv.Itoa(i) + "]"
+	}
+	return steps
+}()
+
+// elemStep returns the path step for the element at index i.
+func elemStep(i int) string {
+	if i < len(elemSteps) {
+		return elemSteps[i]
+	}
+	return "[" + strconv.Itoa(i) + "]"
 }
 
 func anyString(val reflect.Value) string {
 	if val.CanInterface() {
+		if s, ok := basicString(val); ok {
+			return s
+		}
 		return fmt.Sprintf("%#v", val.Interface())
 	}
 	switch val.Kind() {
@@ -224,8 +825,14 @@ func anyString(val reflect.Value) string {
 		return fmt.Sprintf("%#v", val.Bool())
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return fmt.Sprintf("%#v", val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fmt.Sprintf("%#v", val.Uint())
 	case reflect.Float32, reflect.Float64:
 		return fmt.Sprintf("%#v", val.Float())
+	case reflect.Complex64, reflect.Complex128:
+		return fmt.Sprintf("%#v", val.Complex())
+	case reflect.String:
+		return strconv.Quote(val.String())
 	case reflect.Uintptr:
 		return fmt.Sprintf("%d", val.Uint())
 	default:
@@ -233,27 +840,132 @@ func anyString(val reflect.Value) string {
 	}
 }
 
+var goStringerType = reflect.TypeOf((*fmt.GoStringer)(nil)).Elem()
+
+// basicString formats v as appendBasic does.
+func basicString(v reflect.Value) (string, bool) {
+	var buf [32]byte
+	b, ok := appendBasic(buf[:0], v)
+	return string(b), ok
+}
+
+// appendBasic appends v, of a basic kind, to b as %#v formats it, but with
+// strconv, since values are formatted for the trace at every leaf. It
+// reports false for values of other kinds, and of types with a GoString
+// method, which %#v calls.
+func appendBasic(b []byte, v reflect.Value) ([]byte, bool) {
+	if t := v.Type(); t.NumMethod() > 0 && t.Implements(goStringerType) {
+		return b, false
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.AppendBool(b, v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(b, v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(append(b, "0x"...), v.Uint(), 16), true
+	case reflect.Float32:
+		return strconv.AppendFloat(b, v.Float(), 'g', -1, 32), true
+	case reflect.Float64:
+		return strconv.AppendFloat(b, v.Float(), 'g', -1, 64), true
+	case reflect.Complex64:
+		return append(b, strconv.FormatComplex(v.Complex(), 'g', -1, 64)...), true
+	case reflect.Complex128:
+		return append(b, strconv.FormatComplex(v.Complex(), 'g', -1, 128)...), true
+	case reflect.String:
+		return strconv.AppendQuote(b, v.String()), true
+	}
+	return b, false
+}
+
+// appendValue appends v to b as anyString formats it.
+func appendValue(b []byte, v reflect.Value) []byte {
+	if v.CanInterface() {
+		if b, ok := appendBasic(b, v); ok {
+			return b
+		}
+	}
+	return append(b, anyString(v)...)
+}
+
 // DeepEqual tests for deep equality. It uses normal == equality where
 // possible but will scan elements of arrays, slices, maps, and fields of
 // structs. In maps, keys are compared with == but elements use deep
 // equality. DeepEqual correctly handles recursive types. Functions are equal
 // only if they are both nil.
 // An empty slice is not equal to a nil slice.
-func DeepEqual(a1, a2 interface{}) (bool, string) {
+//
+// The trace is only written when the values differ, or at TraceVerbose:
+// equal values are compared without building it, so that checking them
+// costs little more than reflect.DeepEqual. Values that differ are
+// compared a second time to write it.
+func DeepEqual(a1, a2 interface{}, opts ...Option) (bool, string) {
 	if a1 == nil || a2 == nil {
 		return a1 == a2, ""
 	}
-	v1 := reflect.ValueOf(a1)
-	v2 := reflect.ValueOf(a2)
-	if v1.Type() != v2.Type() {
+	return DeepValueEqual(reflect.ValueOf(a1), reflect.ValueOf(a2), opts...)
+}
+
+// DeepEqualT is like DeepEqual, but requires both arguments to have the same
+// static type, so that comparing a *Foo against a Foo is a compile error
+// rather than a silent false.
+func DeepEqualT[T any](a, b T, opts ...Option) (bool, string) {
+	return DeepValueEqual(reflect.ValueOf(&a).Elem(), reflect.ValueOf(&b).Elem(), opts...)
+}
+
+// DeepValueEqual is like DeepEqual but takes reflect.Values directly, for
+// callers that already hold them. Unlike boxing the values back into an
+// interface{}, this keeps addressable values addressable, so recursive
+// structures reached through them are short circuited as usual.
+func DeepValueEqual(v1, v2 reflect.Value, opts ...Option) (bool, string) {
+	return equalValues(&states, newOptions(opts), v1, v2)
+}
+
+// equalValues is DeepValueEqual with its Options applied, taking the state
+// of the comparison from p.
+func equalValues(p *statePool, o *options, v1, v2 reflect.Value) (bool, string) {
+	if !v1.IsValid() || !v2.IsValid() {
+		return v1.IsValid() == v2.IsValid(), ""
+	}
+	if o.reporter == nil && o.level < TraceVerbose {
+		// Compare without a trace first, and only write one, comparing
+		// again, if the values turn out to differ.
+		if eq, _, _ := compareValues(p, v1, v2, o, false, false); eq {
+			return true, ""
+		}
+	}
+	eq, trace, err := compareValues(p, v1, v2, o, false, true)
+	if err != nil {
 		return false, ""
 	}
-	buf := &bytes.Buffer{}
-	s := &deepEqualState{
-		visited: make(map[visit]bool),
-		depth:   -1,
-		sub:     false,
-		w:       buf,
+	return eq, trace
+}
+
+// compareValues is the comparison shared by DeepEqual and Diff, run with
+// a state from p. It returns the trace if trace is set, and carries on past
+// the first mismatch if full is set. v1 and v2 must be valid.
+func compareValues(p *statePool, v1, v2 reflect.Value, o *options, full, trace bool) (bool, string, error) {
+	if v1.Type() != v2.Type() && !(o.equateNumeric && numericClass(v1.Kind()) != notNumeric && numericClass(v2.Kind()) != notNumeric) {
+		return false, "", fmt.Errorf("debugtools: can't compare %s with %s", v1.Type(), v2.Type())
+	}
+	s := p.get(o)
+	defer p.put(s)
+	s.full = full
+	if trace {
+		if s.buf == nil {
+			s.buf = &cappedBuffer{}
+		}
+		s.buf.max = o.maxTrace
+		s.w = s.buf
+	}
+	s.paths = trace || full || o.reporter != nil || o.observe != nil || len(o.ignore) > 0 || len(o.only) > 0
+	if s.opts.reporter != nil {
+		s.opts.reporter.PushStep("")
+		defer s.opts.reporter.PopStep()
+	}
+	eq := s.deepValueEqual(v1, v2)
+	if !trace {
+		return eq, "", nil
 	}
-	return s.deepValueEqual(v1, v2), string(buf.Bytes())
+	return eq, s.buf.String(), nil
 }
@@ -0,0 +1,295 @@
+package debugtools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ChangeKind classifies a Difference.
+type ChangeKind int
+
+const (
+	// Modified means the path exists on both sides with different values.
+	Modified ChangeKind = iota
+	// Inserted means the path only exists on the right.
+	Inserted
+	// Deleted means the path only exists on the left.
+	Deleted
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Modified:
+		return "modified"
+	case Inserted:
+		return "inserted"
+	case Deleted:
+		return "deleted"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// A Difference is a node in a DiffTree. Leaves describe a single change,
+// with the values on each side; the other nodes group the changes beneath
+// their path, and have only a Path and Children.
+type Difference struct {
+	Path    string
+	Kind    ChangeKind
+	Message string
+
+	// Left and Right hold the values on each side, or nil if the path
+	// doesn't exist on that side, is a nil pointer or is unexported.
+	Left, Right interface{}
+	// LeftText and RightText are the values formatted with %#v, or empty
+	// if the path doesn't exist on that side.
+	LeftText, RightText string
+	// LeftType and RightType name the Go types of the values. They are
+	// empty if the path doesn't exist on that side, and in diffs of JSON
+	// and YAML documents.
+	LeftType, RightType string
+
+	Children []*Difference
+}
+
+// IsLeaf reports whether d describes a single change rather than a group.
+func (d *Difference) IsLeaf() bool {
+	return len(d.Children) == 0
+}
+
+// A DiffTree is the result of Diff: a tree holding every difference between
+// two values, rooted at the top-level values.
+type DiffTree struct {
+	// Root is nil if the values are equal.
+	Root *Difference `json:"root"`
+}
+
+// Equal reports whether the values had no differences.
+func (d *DiffTree) Equal() bool {
+	return d.Root == nil
+}
+
+// Leaves returns the individual changes in the tree, in traversal order.
+func (d *DiffTree) Leaves() []*Difference {
+	var leaves []*Difference
+	d.Walk(func(n *Difference) {
+		if n.IsLeaf() {
+			leaves = append(leaves, n)
+		}
+	})
+	return leaves
+}
+
+// Walk calls fn for every node in the tree, parents before their children.
+func (d *DiffTree) Walk(fn func(*Difference)) {
+	var walk func(*Difference)
+	walk = func(n *Difference) {
+		fn(n)
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	if d.Root != nil {
+		walk(d.Root)
+	}
+}
+
+// Render writes the tree to w using f.
+func (d *DiffTree) Render(w io.Writer, f DiffFormatter) error {
+	return f.FormatDiff(w, d)
+}
+
+// String renders the tree with TextFormatter.
+func (d *DiffTree) String() string {
+	buf := &bytes.Buffer{}
+	d.Render(buf, TextFormatter{})
+	return buf.String()
+}
+
+// A DiffFormatter renders a DiffTree.
+type DiffFormatter interface {
+	FormatDiff(w io.Writer, d *DiffTree) error
+}
+
+// TextFormatter renders each change on its own line, marked with "~" for a
+// modification, "+" for an insertion and "-" for a deletion.
+type TextFormatter struct {
+	// Summary writes the tree's Summary before the changes.
+	Summary bool
+}
+
+func (f TextFormatter) FormatDiff(w io.Writer, d *DiffTree) error {
+	if f.Summary {
+		if _, err := io.WriteString(w, d.Summary().String()); err != nil {
+			return err
+		}
+	}
+	for _, n := range d.Leaves() {
+		var err error
+		switch n.Kind {
+		case Inserted:
+			_, err = fmt.Fprintf(w, "+ %s: %s\n", displayPath(n.Path), n.RightText)
+		case Deleted:
+			_, err = fmt.Fprintf(w, "- %s: %s\n", displayPath(n.Path), n.LeftText)
+		default:
+			_, err = fmt.Fprintf(w, "~ %s: %s\n", displayPath(n.Path), n.Message)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// displayPath returns path, or a placeholder for the root.
+func displayPath(path string) string {
+	if path == "" {
+		return "<root>"
+	}
+	return path
+}
+
+// Diff compares a and b like DeepEqual, but rather than stopping at the
+// first mismatch it traverses everything, collecting every difference into
+// a DiffTree that can be rendered in several ways. It returns an error if a
+// and b have different types, since they can't then be compared field by
+// field.
+func Diff(a, b interface{}, opts ...Option) (*DiffTree, error) {
+	if a == nil || b == nil {
+		if a == b {
+			return &DiffTree{}, nil
+		}
+		return &DiffTree{Root: newDifference(Result{
+			Message: fmt.Sprintf("%#v != %#v", a, b),
+			Left:    reflect.ValueOf(a),
+			Right:   reflect.ValueOf(b),
+		})}, nil
+	}
+	return DiffValues(reflect.ValueOf(a), reflect.ValueOf(b), opts...)
+}
+
+// DiffValues is like Diff but takes reflect.Values, as DeepValueEqual does.
+func DiffValues(v1, v2 reflect.Value, opts ...Option) (*DiffTree, error) {
+	if !v1.IsValid() || !v2.IsValid() {
+		if v1.IsValid() == v2.IsValid() {
+			return &DiffTree{}, nil
+		}
+		return &DiffTree{Root: newDifference(Result{Message: "Something is not valid", Left: v1, Right: v2})}, nil
+	}
+	o := newOptions(opts)
+	b := &diffBuilder{collapse: o.collapseReplaced}
+	o.reporter = teeReporter(o.reporter, b)
+	if b.collapse {
+		o.observe = b.observe
+	}
+	if _, _, err := compareValues(&states, v1, v2, o, true, false); err != nil {
+		return nil, err
+	}
+	return &DiffTree{Root: b.root}, nil
+}
+
+// diffBuilder is a Reporter that assembles the mismatches it is told about
+// into a tree of Differences.
+type diffBuilder struct {
+	stack []diffFrame
+	root  *Difference
+	// collapse replaces subtrees with no equal values by a single change.
+	collapse bool
+}
+
+// A diffFrame is a node of the tree under construction.
+type diffFrame struct {
+	d *Difference
+	// sawEqual records whether any value in the subtree compared equal.
+	sawEqual bool
+	// v1 and v2 are the values at the node, recorded when collapsing.
+	v1, v2 reflect.Value
+}
+
+func (b *diffBuilder) PushStep(path string) {
+	b.stack = append(b.stack, diffFrame{d: &Difference{Path: path}})
+}
+
+func (b *diffBuilder) Report(r Result) {
+	f := &b.stack[len(b.stack)-1]
+	if r.Equal {
+		f.sawEqual = true
+		return
+	}
+	n := f.d
+	children := n.Children
+	*n = *newDifference(r)
+	n.Children = children
+}
+
+// observe records the values at the current node, if they haven't been
+// already; values are observed again at the same path beneath pointers
+// and interfaces.
+func (b *diffBuilder) observe(v1, v2 reflect.Value) {
+	f := &b.stack[len(b.stack)-1]
+	if !f.v1.IsValid() && !f.v2.IsValid() {
+		f.v1, f.v2 = v1, v2
+	}
+}
+
+func (b *diffBuilder) PopStep() {
+	f := b.stack[len(b.stack)-1]
+	b.stack = b.stack[:len(b.stack)-1]
+	n := f.d
+	if len(b.stack) > 0 && f.sawEqual {
+		b.stack[len(b.stack)-1].sawEqual = true
+	}
+	if n.Message == "" && len(n.Children) == 0 {
+		return
+	}
+	if b.collapse && !f.sawEqual && len(n.Children) > 0 {
+		changes := len((&DiffTree{Root: n}).Leaves())
+		if changes > 1 {
+			n = newDifference(Result{
+				Path:    n.Path,
+				Message: fmt.Sprintf("Entire subtree replaced (%d changes)", changes),
+				Left:    f.v1,
+				Right:   f.v2,
+			})
+		}
+	}
+	if len(b.stack) == 0 {
+		b.root = n
+	} else {
+		parent := b.stack[len(b.stack)-1].d
+		parent.Children = append(parent.Children, n)
+	}
+}
+
+func newDifference(r Result) *Difference {
+	d := &Difference{Path: r.Path, Message: r.Message}
+	switch {
+	case !r.Left.IsValid():
+		d.Kind = Inserted
+	case !r.Right.IsValid():
+		d.Kind = Deleted
+	}
+	d.Left, d.LeftText = diffValue(r.Left)
+	d.Right, d.RightText = diffValue(r.Right)
+	if r.Left.IsValid() {
+		d.LeftType = r.Left.Type().String()
+	}
+	if r.Right.IsValid() {
+		d.RightType = r.Right.Type().String()
+	}
+	return d
+}
+
+func diffValue(v reflect.Value) (interface{}, string) {
+	if !v.IsValid() {
+		return nil, ""
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, anyString(v)
+	}
+	if v.CanInterface() {
+		return v.Interface(), anyString(v)
+	}
+	return nil, anyString(v)
+}
@@ -0,0 +1,155 @@
+package debugtools
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+// MergeKind classifies a change found by Diff3.
+type MergeKind int
+
+const (
+	// LeftOnly means only the left side changed the path.
+	LeftOnly MergeKind = iota
+	// RightOnly means only the right side changed the path.
+	RightOnly
+	// BothSides means both sides made the same change to the path.
+	BothSides
+	// Conflict means both sides changed the path, or a path within it,
+	// differently.
+	Conflict
+)
+
+func (k MergeKind) String() string {
+	switch k {
+	case LeftOnly:
+		return "left only"
+	case RightOnly:
+		return "right only"
+	case BothSides:
+		return "both sides"
+	case Conflict:
+		return "conflict"
+	}
+	return fmt.Sprintf("MergeKind(%d)", int(k))
+}
+
+// A MergeChange is a path changed from the base by one or both sides.
+type MergeChange struct {
+	Path string
+	Kind MergeKind
+	// Left and Right are the changes to Path made by each side, relative
+	// to the base, or nil if that side didn't change Path itself. In a
+	// conflict, one of them may be nil if the other side changed a path
+	// within or containing Path instead.
+	Left, Right *Difference
+}
+
+// A ThreeWayDiff is the result of Diff3.
+type ThreeWayDiff struct {
+	// Changes holds every changed path, in traversal order of the left
+	// side's changes followed by the right side's.
+	Changes []MergeChange
+}
+
+// Conflicts returns the changes that conflict.
+func (d *ThreeWayDiff) Conflicts() []MergeChange {
+	var conflicts []MergeChange
+	for _, c := range d.Changes {
+		if c.Kind == Conflict {
+			conflicts = append(conflicts, c)
+		}
+	}
+	return conflicts
+}
+
+// String lists the changes, one per line, with how each side changed the
+// path.
+func (d *ThreeWayDiff) String() string {
+	buf := &bytes.Buffer{}
+	for _, c := range d.Changes {
+		fmt.Fprintf(buf, "%s (%v):\n", displayPath(c.Path), c.Kind)
+		if c.Left != nil {
+			fmt.Fprintf(buf, "    left:  %s\n", c.Left.Message)
+		}
+		if c.Right != nil && c.Kind != BothSides {
+			fmt.Fprintf(buf, "    right: %s\n", c.Right.Message)
+		}
+	}
+	return buf.String()
+}
+
+// Diff3 compares left and right each against their common ancestor base,
+// as Diff does, and classifies each changed path by which side changed it.
+// Paths that both sides changed in the same way aren't conflicts; paths
+// that both changed differently are, as are paths that one side changed
+// when the other changed something within them. It returns an error if the
+// three values don't have the same type.
+func Diff3(base, left, right interface{}, opts ...Option) (*ThreeWayDiff, error) {
+	dl, err := Diff(base, left, opts...)
+	if err != nil {
+		return nil, err
+	}
+	dr, err := Diff(base, right, opts...)
+	if err != nil {
+		return nil, err
+	}
+	ll, rl := dl.Leaves(), dr.Leaves()
+	lbyPath := make(map[string]*Difference, len(ll))
+	for _, n := range ll {
+		lbyPath[n.Path] = n
+	}
+	rbyPath := make(map[string]*Difference, len(rl))
+	for _, n := range rl {
+		rbyPath[n.Path] = n
+	}
+	d := &ThreeWayDiff{}
+	for _, l := range ll {
+		c := MergeChange{Path: l.Path, Kind: LeftOnly, Left: l}
+		if r, ok := rbyPath[l.Path]; ok {
+			c.Right = r
+			c.Kind = Conflict
+			if sameChange(l, r) {
+				c.Kind = BothSides
+			}
+		} else if overlaps(l.Path, rl) {
+			c.Kind = Conflict
+		}
+		d.Changes = append(d.Changes, c)
+	}
+	for _, r := range rl {
+		if _, ok := lbyPath[r.Path]; ok {
+			continue
+		}
+		c := MergeChange{Path: r.Path, Kind: RightOnly, Right: r}
+		if overlaps(r.Path, ll) {
+			c.Kind = Conflict
+		}
+		d.Changes = append(d.Changes, c)
+	}
+	return d, nil
+}
+
+// sameChange reports whether l and r change a path to the same value.
+func sameChange(l, r *Difference) bool {
+	if l.Kind != r.Kind || l.RightText != r.RightText {
+		return false
+	}
+	if l.Right == nil || r.Right == nil {
+		return l.Right == nil && r.Right == nil
+	}
+	eq, _ := DeepValueEqual(reflect.ValueOf(l.Right), reflect.ValueOf(r.Right))
+	return eq
+}
+
+// overlaps reports whether any of the changes is at a path strictly within
+// or containing path.
+func overlaps(path string, changes []*Difference) bool {
+	for _, n := range changes {
+		if n.Path != path && (pathWithin(n.Path, path) || pathWithin(path, n.Path)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package debugtools
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MarshalText encodes k as its String form.
+func (k ChangeKind) MarshalText() ([]byte, error) {
+	switch k {
+	case Modified, Inserted, Deleted:
+		return []byte(k.String()), nil
+	}
+	return nil, fmt.Errorf("debugtools: invalid ChangeKind %d", int(k))
+}
+
+// UnmarshalText decodes a ChangeKind from its String form.
+func (k *ChangeKind) UnmarshalText(b []byte) error {
+	for _, kind := range []ChangeKind{Modified, Inserted, Deleted} {
+		if string(b) == kind.String() {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("debugtools: invalid ChangeKind %q", b)
+}
+
+// serializedDifference is the JSON form of a Difference.
+type serializedDifference struct {
+	Path      string          `json:"path"`
+	Kind      ChangeKind      `json:"kind"`
+	Message   string          `json:"message,omitempty"`
+	Left      json.RawMessage `json:"left,omitempty"`
+	LeftType  string          `json:"leftType,omitempty"`
+	LeftText  string          `json:"leftText,omitempty"`
+	Right     json.RawMessage `json:"right,omitempty"`
+	RightType string          `json:"rightType,omitempty"`
+	RightText string          `json:"rightText,omitempty"`
+	Children  []*Difference   `json:"children,omitempty"`
+}
+
+// MarshalJSON encodes d and its children, so that a DiffTree can be stored
+// and later decoded and rendered with any DiffFormatter. Left and Right are
+// encoded as JSON; values that can't be are left out, keeping only their
+// text and type name.
+func (d *Difference) MarshalJSON() ([]byte, error) {
+	sd := serializedDifference{
+		Path:      d.Path,
+		Kind:      d.Kind,
+		Message:   d.Message,
+		LeftType:  d.LeftType,
+		LeftText:  d.LeftText,
+		RightType: d.RightType,
+		RightText: d.RightText,
+		Children:  d.Children,
+	}
+	sd.Left = marshalDiffValue(d.Left)
+	sd.Right = marshalDiffValue(d.Right)
+	return json.Marshal(sd)
+}
+
+// UnmarshalJSON decodes a Difference encoded by MarshalJSON. As the Go
+// types of Left and Right aren't available, only their names, the values
+// are decoded as generic JSON values, with numbers as json.Number.
+func (d *Difference) UnmarshalJSON(b []byte) error {
+	var sd serializedDifference
+	if err := json.Unmarshal(b, &sd); err != nil {
+		return err
+	}
+	*d = Difference{
+		Path:      sd.Path,
+		Kind:      sd.Kind,
+		Message:   sd.Message,
+		LeftType:  sd.LeftType,
+		LeftText:  sd.LeftText,
+		RightType: sd.RightType,
+		RightText: sd.RightText,
+		Children:  sd.Children,
+	}
+	var err error
+	if d.Left, err = unmarshalDiffValue(sd.Left); err != nil {
+		return err
+	}
+	d.Right, err = unmarshalDiffValue(sd.Right)
+	return err
+}
+
+func marshalDiffValue(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func unmarshalDiffValue(b json.RawMessage) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	return decodeJSON(b)
+}
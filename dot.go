@@ -0,0 +1,372 @@
+package debugtools
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// DOTFormatter renders a DiffTree in the Graphviz DOT language.
+//
+// If Value is set, it should be the left-hand value the tree was computed
+// from, and the graph drawn is Value's object graph: one node per value,
+// with each pointer target drawn once however many paths lead to it, so
+// that sharing and cycles are visible. Nodes that changed are filled by
+// the kind of change, nodes containing changes are outlined, and values
+// only present on the right are drawn dashed. If Value is nil, the graph
+// drawn is the DiffTree itself.
+type DOTFormatter struct {
+	Value interface{}
+}
+
+// dotLabelWidth is the maximum length of value labels in the graph.
+const dotLabelWidth = 40
+
+func (f DOTFormatter) FormatDiff(w io.Writer, d *DiffTree) error {
+	g := &dotGraph{
+		w:         bufio.NewWriter(w),
+		ids:       make(map[dotKey]string),
+		pathIDs:   make(map[string]string),
+		changes:   make(map[string]*Difference),
+		ancestors: make(map[string]bool),
+	}
+	var walk func(n *Difference)
+	walk = func(n *Difference) {
+		if n.IsLeaf() {
+			g.changes[n.Path] = n
+			return
+		}
+		g.ancestors[n.Path] = true
+		for _, c := range n.Children {
+			walk(c)
+		}
+	}
+	if d.Root != nil {
+		walk(d.Root)
+	}
+	g.w.WriteString("digraph diff {\n\tnode [shape=box, fontname=monospace];\n\tedge [fontname=monospace];\n")
+	if f.Value != nil {
+		g.value(reflect.ValueOf(f.Value), "")
+		g.inserted(d.Root)
+	} else if d.Root != nil {
+		g.tree(d.Root)
+	}
+	g.w.WriteString("}\n")
+	return g.w.Flush()
+}
+
+type dotGraph struct {
+	w   *bufio.Writer
+	n   int
+	ids map[dotKey]string
+	// pathIDs holds the node for each path drawn, so that insertions can
+	// be attached to their container.
+	pathIDs   map[string]string
+	changes   map[string]*Difference
+	ancestors map[string]bool
+}
+
+// A dotKey identifies the target of a pointer, map or slice.
+type dotKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// value draws v, found at path, and returns its node.
+func (g *dotGraph) value(v reflect.Value, path string) string {
+	var key dotKey
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map:
+		if !v.IsNil() {
+			key = dotKey{v.Pointer(), v.Type(), 0}
+		}
+	case reflect.Slice:
+		if v.Len() > 0 {
+			key = dotKey{v.Pointer(), v.Type(), v.Len()}
+		}
+	}
+	if key.ptr != 0 {
+		if id, ok := g.ids[key]; ok {
+			return id
+		}
+	}
+	g.n++
+	id := fmt.Sprintf("n%d", g.n)
+	if key.ptr != 0 {
+		g.ids[key] = id
+	}
+	if _, ok := g.pathIDs[path]; !ok {
+		g.pathIDs[path] = id
+	}
+
+	label := v.Type().String()
+	var edges []string
+	edge := func(step string, child reflect.Value, childPath string) {
+		cid := g.value(child, childPath)
+		edges = append(edges, fmt.Sprintf("\t%s -> %s [label=%s];\n", id, cid, dotQuote(step)))
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			label += "\nnil"
+		} else {
+			edge("*", v.Elem(), path)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name := v.Type().Field(i).Name
+			edge(name, v.Field(i), path+"."+name)
+		}
+	case reflect.Slice, reflect.Array:
+		label += fmt.Sprintf("\nlen %d", v.Len())
+		for i := 0; i < v.Len(); i++ {
+			step := fmt.Sprintf("[%d]", i)
+			edge(step, v.Index(i), path+step)
+		}
+	case reflect.Map:
+		label += fmt.Sprintf("\nlen %d", v.Len())
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return anyString(keys[i]) < anyString(keys[j]) })
+		for _, k := range keys {
+			step := "[" + anyString(k) + "]"
+			edge(step, v.MapIndex(k), path+step)
+		}
+	default:
+		label += "\n" + truncateLabel(anyString(v))
+	}
+	g.node(id, label, path)
+	for _, e := range edges {
+		g.w.WriteString(e)
+	}
+	return id
+}
+
+// inserted draws the values in n that are only present on the right,
+// attached to the nodes of their containers.
+func (g *dotGraph) inserted(n *Difference) {
+	if n == nil {
+		return
+	}
+	for _, c := range n.Children {
+		if c.IsLeaf() && c.Kind == Inserted {
+			parent, ok := g.pathIDs[n.Path]
+			if !ok {
+				continue
+			}
+			g.n++
+			id := fmt.Sprintf("n%d", g.n)
+			g.node(id, truncateLabel(c.RightText), c.Path)
+			fmt.Fprintf(g.w, "\t%s -> %s [label=%s, style=dashed];\n", parent, id, dotQuote(strings.TrimPrefix(c.Path, n.Path)))
+		}
+		g.inserted(c)
+	}
+}
+
+// tree draws n and the differences beneath it.
+func (g *dotGraph) tree(n *Difference) string {
+	g.n++
+	id := fmt.Sprintf("n%d", g.n)
+	label := displayPath(n.Path)
+	if n.IsLeaf() {
+		label += "\n" + truncateLabel(n.Message)
+	}
+	g.node(id, label, n.Path)
+	for _, c := range n.Children {
+		fmt.Fprintf(g.w, "\t%s -> %s;\n", id, g.tree(c))
+	}
+	return id
+}
+
+// node writes the node id, styled by the change at path, if any.
+func (g *dotGraph) node(id, label, path string) {
+	attrs := "label=" + dotQuote(label)
+	if c, ok := g.changes[path]; ok {
+		color := map[ChangeKind]string{Modified: "#fff5cc", Inserted: "#dcffe4", Deleted: "#ffdce0"}[c.Kind]
+		style := "filled"
+		if c.Kind == Inserted {
+			style = `"filled,dashed"`
+		}
+		attrs += ", style=" + style + `, fillcolor="` + color + `"`
+		if c.Kind == Modified && c.RightText != "" {
+			attrs += ", tooltip=" + dotQuote("right: "+c.RightText)
+		}
+	} else if g.ancestors[path] {
+		attrs += ", penwidth=2"
+	}
+	fmt.Fprintf(g.w, "\t%s [%s];\n", id, attrs)
+}
+
+func truncateLabel(s string) string {
+	if utf8.RuneCountInString(s) <= dotLabelWidth {
+		return s
+	}
+	return string([]rune(s)[:dotLabelWidth-1]) + "…"
+}
+
+// dotEscaper escapes strings for DOT, with newlines as centered line
+// breaks.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// dotQuote quotes s as a DOT string.
+func dotQuote(s string) string {
+	return `"` + dotEscaper.Replace(s) + `"`
+}
+
+// dotMaxLines is the maximum number of lines in the label of an allocation
+// drawn by GraphDOT.
+const dotMaxLines = 20
+
+// GraphDOT returns v's object graph in the Graphviz DOT language, with a
+// node for each allocation, labeled with the values stored in it, and an
+// edge for each pointer, map or slice leading to another allocation,
+// labeled with its path within the allocation. Memory reachable along
+// several paths is drawn once, so aliasing and cycles show up as edges
+// converging on the same node.
+func GraphDOT(v interface{}) string {
+	g := &allocGraph{ids: make(map[dotKey]string)}
+	g.buf.WriteString("digraph value {\n\tnode [shape=box, fontname=monospace];\n\tedge [fontname=monospace];\n")
+	if v != nil {
+		rv := reflect.ValueOf(v)
+		if !g.reference(rv, "", nil) {
+			g.allocation(rv.Type().String(), func(a *allocNode) { a.contents(rv, "") })
+		}
+	}
+	g.buf.WriteString("}\n")
+	return g.buf.String()
+}
+
+type allocGraph struct {
+	buf strings.Builder
+	n   int
+	ids map[dotKey]string
+}
+
+// An allocNode collects the label and edges of an allocation being drawn.
+type allocNode struct {
+	g     *allocGraph
+	id    string
+	lines []string
+	more  int
+	edges []string
+}
+
+// allocation draws a node headed by title, whose contents are added by
+// fill, and returns its id.
+func (g *allocGraph) allocation(title string, fill func(a *allocNode)) string {
+	g.n++
+	a := &allocNode{g: g, id: fmt.Sprintf("n%d", g.n), lines: []string{title}}
+	fill(a)
+	if a.more > 0 {
+		a.lines = append(a.lines, fmt.Sprintf("… %d more", a.more))
+	}
+	var label strings.Builder
+	for _, l := range a.lines {
+		label.WriteString(dotEscaper.Replace(truncateLabel(l)))
+		label.WriteString(`\l`)
+	}
+	fmt.Fprintf(&g.buf, "\t%s [label=\"%s\"];\n", a.id, label.String())
+	for _, e := range a.edges {
+		g.buf.WriteString(e)
+	}
+	return a.id
+}
+
+// reference draws the allocation v refers to, if v is a non-nil pointer,
+// map or slice, and reports whether it did. If from is set, an edge labeled
+// path is drawn to it from there.
+func (g *allocGraph) reference(v reflect.Value, path string, from *allocNode) bool {
+	var key dotKey
+	var title string
+	var fill func(a *allocNode)
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return false
+		}
+		key = dotKey{v.Pointer(), v.Type(), 0}
+		e := v.Elem()
+		title = e.Type().String()
+		fill = func(a *allocNode) { a.contents(e, "") }
+	case reflect.Map:
+		if v.IsNil() {
+			return false
+		}
+		key = dotKey{v.Pointer(), v.Type(), 0}
+		title = fmt.Sprintf("%s len %d", v.Type(), v.Len())
+		fill = func(a *allocNode) {
+			for _, k := range sortedMapKeys(v) {
+				a.contents(v.MapIndex(k), "["+anyString(k)+"]")
+			}
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return false
+		}
+		key = dotKey{v.Pointer(), v.Type(), v.Len()}
+		title = fmt.Sprintf("%s len %d cap %d", v.Type(), v.Len(), v.Cap())
+		fill = func(a *allocNode) {
+			for i := 0; i < v.Len(); i++ {
+				a.contents(v.Index(i), fmt.Sprintf("[%d]", i))
+			}
+		}
+	default:
+		return false
+	}
+	id, ok := g.ids[key]
+	if !ok {
+		// Reserve the id before filling the node, so that cycles lead
+		// back to it.
+		id = fmt.Sprintf("n%d", g.n+1)
+		g.ids[key] = id
+		g.allocation(title, fill)
+	}
+	if from != nil {
+		from.edges = append(from.edges, fmt.Sprintf("\t%s -> %s [label=%s];\n", from.id, id, dotQuote(path)))
+	}
+	return true
+}
+
+// contents adds v, stored in the allocation at path, to its label, and
+// edges to whatever v refers to.
+func (a *allocNode) contents(v reflect.Value, path string) {
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			a.contents(v.Field(i), path+"."+v.Type().Field(i).Name)
+		}
+		return
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			a.contents(v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+		return
+	case reflect.Interface:
+		if !v.IsNil() {
+			a.contents(v.Elem(), path)
+			return
+		}
+	}
+	if a.g.reference(v, path, a) {
+		return
+	}
+	var s string
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		s = "nil"
+	default:
+		s = anyString(v)
+	}
+	if path == "" {
+		path = "*"
+	}
+	if len(a.lines) > dotMaxLines {
+		a.more++
+		return
+	}
+	a.lines = append(a.lines, path+": "+s)
+}
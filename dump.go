@@ -0,0 +1,789 @@
+package debugtools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// Dump writes v to standard output as an indented tree, with the type of
+// every value, the lengths of strings, slices and maps, and the fields of
+// structs, going through pointers and interfaces to the values they refer
+// to. Times are shown in RFC 3339 format, and types with an Error or
+// String method by calling it. A pointer back to a value that is already
+// being dumped is shown as <cycle> rather than followed again. Map entries
+// are sorted by key, so that dumps of equal values can be compared with
+// diff. Unexported struct fields are left out unless DumpUnexported is
+// given, and the values of fields tagged `dump:"redact"`, such as
+// passwords, are shown as <redacted>. The output is colored when it goes
+// to a terminal; see DumpColor. For example:
+//
+//	(*main.Order) {
+//	  ID: (int) 7,
+//	  Items: ([]string) (len=2 cap=2) {
+//	    (string) (len=3) "pen",
+//	    (string) (len=5) "paper",
+//	  },
+//	}
+func Dump(v interface{}, opts ...Option) {
+	dumpValue(os.Stdout, v, newOptions(opts))
+}
+
+// Sdump returns the dump of v, as Dump would write it, as a string. With
+// DumpCompact, the final line break is left off.
+func Sdump(v interface{}, opts ...Option) string {
+	o := newOptions(opts)
+	buf := &bytes.Buffer{}
+	dumpValue(buf, v, o)
+	if o.dumpCompact {
+		return strings.TrimSuffix(buf.String(), "\n")
+	}
+	return buf.String()
+}
+
+// Fdump writes the dump of v, as Dump would write it, to w. Like Dump, it
+// writes as it goes, buffering only a few kilobytes at a time, so that even
+// a value too big to dump into a string can be dumped to a file.
+func Fdump(w io.Writer, v interface{}, opts ...Option) {
+	dumpValue(w, v, newOptions(opts))
+}
+
+// dumpBufferSize is the size of the buffer a dump is written through.
+const dumpBufferSize = 32 << 10
+
+func dumpValue(w io.Writer, v interface{}, o *options) {
+	bw := bufio.NewWriterSize(w, dumpBufferSize)
+	defer bw.Flush()
+	d := &dumpState{w: bw, opts: o, indent: "  ", width: 80, visited: make(map[visit]bool)}
+	if o.dumpGoSyntax {
+		d.indent = "\t"
+	}
+	if o.dumpIndent != nil {
+		d.indent = *o.dumpIndent
+	}
+	if o.dumpWidth > 0 {
+		d.width = o.dumpWidth
+	}
+	d.color = !o.dumpGoSyntax && !o.dumpCompact && useColor(w, o.dumpColor)
+	if o.dumpGoSyntax {
+		d.goDump(v)
+		return
+	}
+	if o.dumpCompact {
+		if v == nil {
+			d.printf("nil")
+		} else {
+			d.compactValue(reflect.ValueOf(v), false)
+			d.run(0)
+		}
+		d.printf("\n")
+		return
+	}
+	if v == nil {
+		d.paint(colorType, "(interface {})")
+		d.printf(" ")
+		d.paint(colorNil, "nil")
+		d.printf("\n")
+		return
+	}
+	d.value(reflect.ValueOf(v))
+	d.run(0)
+	d.printf("\n")
+}
+
+// DumpIndent sets the string each level of a dump is indented by. The
+// default is two spaces, or a tab with DumpGoSyntax.
+func DumpIndent(indent string) Option {
+	return func(o *options) {
+		o.dumpIndent = &indent
+	}
+}
+
+// DumpWidth sets the width of the lines DumpInline fits composites within.
+// The default is 80.
+func DumpWidth(n int) Option {
+	return func(o *options) {
+		o.dumpWidth = n
+	}
+}
+
+// DumpInline writes structs, slices, arrays and maps that fit within the
+// DumpWidth on a single line, as in {X: (int) 1, Y: (int) 2}, rather than
+// one field or element per line.
+func DumpInline() Option {
+	return func(o *options) {
+		o.dumpInline = true
+	}
+}
+
+// DumpAddresses writes the address held by each non-nil pointer, map,
+// slice and channel after its type, as in (*main.Order)(0xc0000a4000), so
+// that values shared between several parts of a dump can be recognized.
+func DumpAddresses() Option {
+	return func(o *options) {
+		o.dumpAddresses = true
+	}
+}
+
+// DumpUnexported includes unexported struct fields in a dump, reading them
+// with package unsafe, so that the internal state of types from other
+// packages can be inspected. Along with DumpNoMethods it shows, for
+// example, the wall clock and location inside a time.Time.
+func DumpUnexported() Option {
+	return func(o *options) {
+		o.dumpUnexported = true
+	}
+}
+
+// DumpNoMethods writes values with an Error or String method by their
+// contents, like any other value, rather than by calling the method.
+func DumpNoMethods() Option {
+	return func(o *options) {
+		o.dumpNoMethods = true
+	}
+}
+
+// DumpTags shows the struct tags of each field after its name, as in
+//
+//	Name `json:"name,omitempty"`: (string) (len=3) "Ada",
+//
+// to help find out why a field wasn't marshaled or validated as expected.
+func DumpTags() Option {
+	return func(o *options) {
+		o.dumpTags = true
+	}
+}
+
+// DumpRedactPaths shows the values at the given paths, written as for
+// IgnorePaths, as <redacted>, as though they were fields tagged
+// `dump:"redact"`, for values whose types can't be tagged. Paths within
+// map keys and slices, such as "Users[2].Token", can be redacted as well.
+func DumpRedactPaths(paths ...string) Option {
+	return func(o *options) {
+		o.dumpRedact = append(o.dumpRedact, normalizePaths(paths)...)
+	}
+}
+
+// DumpTruncate shortens strings and byte slices longer than 2n bytes to
+// their first and last n bytes, so that large payloads don't swamp a dump.
+// Their full length is still shown. In DumpJSON, DumpYAML and DumpAttrs,
+// long strings are shortened in the same way, with their length appended,
+// as in "abc…xyz (len=52431)". It doesn't apply to DumpGoSyntax. Zero, the
+// default, means no limit.
+func DumpTruncate(n int) Option {
+	return func(o *options) {
+		o.dumpTruncate = n
+	}
+}
+
+// DumpMaxDepth stops a dump from descending more than n levels into a
+// value: the contents of structs, slices, arrays and maps n steps or more
+// from the top are shown as <max depth>. It doesn't apply to DumpGoSyntax,
+// whose output must remain valid Go. Zero means no limit.
+func DumpMaxDepth(n int) Option {
+	return func(o *options) {
+		o.dumpMaxDepth = n
+	}
+}
+
+// beyondMaxDepth reports whether the contents of a composite value are
+// left out of a dump at the given number of steps from the top.
+func (o *options) beyondMaxDepth(steps int) bool {
+	return o.dumpMaxDepth > 0 && steps >= o.dumpMaxDepth
+}
+
+// dumpState holds the progress of a Dump, in the same way deepEqualState
+// does for a comparison.
+type dumpState struct {
+	w      io.Writer
+	opts   *options
+	indent string
+	width  int
+	depth  int
+	path   []string
+	// col is the column reached on the current line.
+	col int
+	// inline is set while trying to write a block on a single line, and
+	// overflow once it has turned out not to fit.
+	inline, overflow bool
+	color            bool
+	// visited holds the pointers, maps and slices being dumped on the
+	// current path, keyed by sliceVisit for slices, to detect cycles.
+	visited map[visit]bool
+	// decls holds the helper variables declared so far in Go syntax mode.
+	decls []string
+	// todo holds the steps left to run, the next one last. Values are
+	// written by running steps from it rather than by recursion, as
+	// deepEqualState uses its stack of frames, so that a deeply nested
+	// value such as a long linked list can't exhaust the goroutine's stack.
+	todo []func()
+}
+
+// then schedules step to run once the current step, and the steps it has
+// scheduled before, are done.
+func (d *dumpState) then(step func()) {
+	d.todo = append(d.todo, step)
+}
+
+// run runs the steps scheduled since d.todo held n steps, and the steps
+// they schedule in turn, until it holds n again.
+func (d *dumpState) run(n int) {
+	reverseSteps(d.todo[n:])
+	for len(d.todo) > n {
+		step := d.todo[len(d.todo)-1]
+		d.todo[len(d.todo)-1] = nil
+		d.todo = d.todo[:len(d.todo)-1]
+		m := len(d.todo)
+		step()
+		reverseSteps(d.todo[m:])
+	}
+}
+
+// reverseSteps reverses steps, which then scheduled in order, so that the
+// first is run first.
+func reverseSteps(steps []func()) {
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+}
+
+// each calls entry for each i from 0 to n-1, once the steps scheduled for
+// the previous entry are done, and then done. Entries left once the line
+// has overflowed are skipped.
+func (d *dumpState) each(n int, entry func(i int), done func()) {
+	var next func(i int)
+	next = func(i int) {
+		if i == n || d.overflow {
+			done()
+			return
+		}
+		entry(i)
+		d.then(func() { next(i + 1) })
+	}
+	next(0)
+}
+
+func (d *dumpState) printf(format string, vals ...interface{}) {
+	if d.overflow {
+		return
+	}
+	s := fmt.Sprintf(format, vals...)
+	i := strings.LastIndexByte(s, '\n')
+	col := d.col + utf8.RuneCountInString(s)
+	if i >= 0 {
+		col = utf8.RuneCountInString(s[i+1:])
+	}
+	if d.inline && (i >= 0 || col >= d.width) {
+		// An inlined block must fit on the line, with room for a comma.
+		d.overflow = true
+		return
+	}
+	d.col = col
+	io.WriteString(d.w, s)
+}
+
+// newline ends the current line and indents the next one.
+func (d *dumpState) newline() {
+	d.printf("\n%s", strings.Repeat(d.indent, d.depth))
+}
+
+// skip reports whether the subtree at step below the current path is left
+// out by IgnorePaths or OnlyPaths.
+func (d *dumpState) skip(step string) bool {
+	if len(d.opts.ignore) == 0 && len(d.opts.only) == 0 {
+		return false
+	}
+	return d.opts.excluded(strings.Join(d.path, "") + step)
+}
+
+// redacted reports whether the value at step below the current path is
+// redacted by DumpRedactPaths.
+func (d *dumpState) redacted(step string) bool {
+	if len(d.opts.dumpRedact) == 0 {
+		return false
+	}
+	path := strings.Join(d.path, "") + step
+	for _, p := range d.opts.dumpRedact {
+		if pathWithin(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// shownIndexes returns the indexes of the first n elements of a slice or
+// array that aren't skipped.
+func (d *dumpState) shownIndexes(n int) []int {
+	idx := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		if !d.skip("[" + strconv.Itoa(i) + "]") {
+			idx = append(idx, i)
+		}
+	}
+	return idx
+}
+
+// shownKeys returns the map keys that aren't skipped.
+func (d *dumpState) shownKeys(keys []reflect.Value) []reflect.Value {
+	shown := keys[:0]
+	for _, k := range keys {
+		if !d.skip("[" + anyString(k) + "]") {
+			shown = append(shown, k)
+		}
+	}
+	return shown
+}
+
+func (d *dumpState) pushStep(step string) {
+	d.path = append(d.path, step)
+}
+
+func (d *dumpState) popStep() {
+	d.path = d.path[:len(d.path)-1]
+}
+
+// value writes v preceded by its type. The value held by an interface is
+// preceded by both the interface type and its dynamic type, as in
+// error(*fs.PathError), unless the interface is empty.
+func (d *dumpState) value(v reflect.Value) {
+	if d.redacted("") {
+		d.paint(colorType, "(%s)", v.Type())
+		d.printf(" <redacted>")
+		return
+	}
+	typ := v.Type().String()
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		if v.NumMethod() > 0 {
+			typ = interfaceTypeString(v)
+		} else {
+			typ = v.Elem().Type().String()
+		}
+		v = v.Elem()
+	}
+	d.paint(colorType, "(%s)", typ)
+	if d.opts.dumpAddresses {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan, reflect.UnsafePointer:
+			if !v.IsNil() {
+				d.printf("(%#x)", v.Pointer())
+			}
+		}
+	}
+	d.printf(" ")
+	if s, ok := d.valueString(v); ok {
+		d.printf("%s", s)
+		return
+	}
+	d.body(v)
+}
+
+// body writes v without its type.
+func (d *dumpState) body(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		d.paint(colorNumber, "%t", v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		d.paint(colorNumber, "%d", v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		d.paint(colorNumber, "%d", v.Uint())
+	case reflect.Float32, reflect.Float64:
+		d.paint(colorNumber, "%s", strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()))
+	case reflect.Complex64, reflect.Complex128:
+		d.paint(colorNumber, "%s", strconv.FormatComplex(v.Complex(), 'g', -1, v.Type().Bits()))
+	case reflect.String:
+		d.printf("(len=%d) ", v.Len())
+		if head, tail, ok := truncateString(v.String(), d.opts.dumpTruncate); ok {
+			d.paint(colorString, "%s…%s", strconv.Quote(head), strconv.Quote(tail))
+		} else {
+			d.quote(v.String())
+		}
+	case reflect.Interface:
+		d.paint(colorNil, "nil")
+	case reflect.Ptr:
+		d.pointer(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			d.paint(colorNil, "nil")
+			return
+		}
+		key := sliceVisit(v)
+		if d.visited[key] {
+			d.printf("<cycle>")
+			return
+		}
+		d.visited[key] = true
+		d.printf("(len=%d cap=%d) ", v.Len(), v.Cap())
+		d.elements(v)
+		d.then(func() { delete(d.visited, key) })
+	case reflect.Array:
+		d.printf("(len=%d) ", v.Len())
+		d.elements(v)
+	case reflect.Map:
+		d.mapEntries(v)
+	case reflect.Struct:
+		d.fields(v)
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		if v.IsNil() {
+			d.paint(colorNil, "nil")
+		} else {
+			d.printf("%#x", v.Pointer())
+		}
+	default:
+		d.printf("%s", anyString(v))
+	}
+}
+
+// quoteChunkSize is the number of bytes of a string quoted at a time.
+const quoteChunkSize = 4 << 10
+
+// quote writes s quoted, a chunk at a time, so that a huge string isn't
+// copied whole.
+func (d *dumpState) quote(s string) {
+	if len(s) <= quoteChunkSize {
+		d.paint(colorString, "%s", strconv.Quote(s))
+		return
+	}
+	if d.color {
+		io.WriteString(d.w, colorString)
+		defer io.WriteString(d.w, colorReset)
+	}
+	d.printf(`"`)
+	for len(s) > 0 && !d.overflow {
+		// Split between runes, so that each is quoted whole.
+		n := quoteChunkSize
+		for n < len(s) && n < quoteChunkSize+utf8.UTFMax && !utf8.RuneStart(s[n]) {
+			n++
+		}
+		if n > len(s) {
+			n = len(s)
+		}
+		q := strconv.Quote(s[:n])
+		d.printf("%s", q[1:len(q)-1])
+		s = s[n:]
+	}
+	d.printf(`"`)
+}
+
+// sliceVisit returns the key of the slice v in dumpState.visited: its
+// backing array, and its length, since a shorter slice of the same array
+// held in one of its elements is a different value rather than a cycle.
+func sliceVisit(v reflect.Value) visit {
+	return visit{a1: v.Pointer(), a2: uintptr(v.Len()), typ: v.Type()}
+}
+
+func (d *dumpState) pointer(v reflect.Value) {
+	if v.IsNil() {
+		d.paint(colorNil, "nil")
+		return
+	}
+	key := visit{a1: v.Pointer(), typ: v.Type()}
+	if d.visited[key] {
+		d.printf("<cycle>")
+		return
+	}
+	e := v.Elem()
+	if s, ok := d.valueString(e); ok {
+		d.printf("%s", s)
+		return
+	}
+	d.visited[key] = true
+	d.body(e)
+	d.then(func() { delete(d.visited, key) })
+}
+
+// elements writes the elements of a slice or array.
+func (d *dumpState) elements(v reflect.Value) {
+	idx := d.shownIndexes(v.Len())
+	n := d.opts.dumpTruncate
+	if v.Type().Elem().Kind() != reflect.Uint8 || n <= 0 || len(idx) <= 2*n {
+		d.block(len(idx), func(i int) {
+			d.element(v, idx[i])
+		})
+		return
+	}
+	// The first and last n bytes, with a note of how many are left out in
+	// between.
+	d.block(2*n+1, func(i int) {
+		switch {
+		case i < n:
+			d.element(v, idx[i])
+		case i == n:
+			d.printf("… (%d more)", len(idx)-2*n)
+		default:
+			d.element(v, idx[len(idx)-2*n+i-1])
+		}
+	})
+}
+
+func (d *dumpState) element(v reflect.Value, i int) {
+	d.pushStep("[" + strconv.Itoa(i) + "]")
+	d.value(v.Index(i))
+	d.then(d.popStep)
+}
+
+// truncateString returns the first and last n bytes of s, adjusted so as
+// not to split a UTF-8 sequence, if s is longer than 2n bytes and n is
+// positive.
+func truncateString(s string, n int) (head, tail string, ok bool) {
+	if n <= 0 || len(s) <= 2*n {
+		return "", "", false
+	}
+	i, j := n, len(s)-n
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	for j < len(s) && !utf8.RuneStart(s[j]) {
+		j++
+	}
+	return s[:i], s[j:], true
+}
+
+// truncatedString returns s shortened by truncateString, with its length
+// added, as in "abc…xyz (len=52431)".
+func truncatedString(s string, n int) string {
+	if head, tail, ok := truncateString(s, n); ok {
+		return head + "…" + tail + " (len=" + strconv.Itoa(len(s)) + ")"
+	}
+	return s
+}
+
+func (d *dumpState) mapEntries(v reflect.Value) {
+	if v.IsNil() {
+		d.paint(colorNil, "nil")
+		return
+	}
+	key := visit{a1: v.Pointer(), typ: v.Type()}
+	if d.visited[key] {
+		d.printf("<cycle>")
+		return
+	}
+	d.visited[key] = true
+	d.printf("(len=%d) ", v.Len())
+	keys := d.shownKeys(sortedMapKeys(v))
+	d.block(len(keys), func(i int) {
+		d.pushStep("[" + anyString(keys[i]) + "]")
+		d.value(keys[i])
+		d.then(func() {
+			d.printf(": ")
+			d.value(v.MapIndex(keys[i]))
+			d.then(d.popStep)
+		})
+	})
+	d.then(func() { delete(d.visited, key) })
+}
+
+func (d *dumpState) fields(v reflect.Value) {
+	v = d.addressable(v)
+	t := v.Type()
+	var shown []int
+	for i := 0; i < t.NumField(); i++ {
+		if (t.Field(i).IsExported() || d.opts.dumpUnexported) && !d.skip("."+t.Field(i).Name) {
+			shown = append(shown, i)
+		}
+	}
+	d.block(len(shown), func(i int) {
+		name := t.Field(shown[i]).Name
+		d.pushStep("." + name)
+		d.paint(colorField, "%s", name)
+		if tag := t.Field(shown[i]).Tag; d.opts.dumpTags && tag != "" {
+			d.printf(" `%s`", tag)
+		}
+		d.printf(": ")
+		if hasTagFlag(t.Field(shown[i]), dumpTagKey, "redact") {
+			d.paint(colorType, "(%s)", t.Field(shown[i]).Type)
+			d.printf(" <redacted>")
+		} else {
+			d.value(d.field(v, shown[i]))
+		}
+		d.then(d.popStep)
+	})
+}
+
+// addressable returns v, or for DumpUnexported a copy of v that can be
+// addressed, so that field can read its unexported fields.
+func (d *dumpState) addressable(v reflect.Value) reflect.Value {
+	if !d.opts.dumpUnexported || v.CanAddr() || !v.CanInterface() {
+		return v
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
+
+// field returns the i'th field of the struct v. For DumpUnexported, an
+// unexported field of an addressable struct is returned as if it were
+// exported, so that its methods can be called and its contents read like
+// any other value.
+func (d *dumpState) field(v reflect.Value, i int) reflect.Value {
+	f := v.Field(i)
+	if !d.opts.dumpUnexported || f.CanInterface() || !f.CanAddr() {
+		return f
+	}
+	return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+}
+
+// sortedMapKeys returns the keys of the map v in order, so that dumps of
+// the same map are identical. Numbers are ordered by value, and other keys
+// by their formatting; keys of different dynamic types are grouped by type.
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return lessMapKey(keys[i], keys[j])
+	})
+	return keys
+}
+
+func lessMapKey(k1, k2 reflect.Value) bool {
+	if k1.Kind() == reflect.Interface {
+		if k1.IsNil() || k2.IsNil() {
+			return k1.IsNil() && !k2.IsNil()
+		}
+		k1, k2 = k1.Elem(), k2.Elem()
+		if t1, t2 := k1.Type().String(), k2.Type().String(); t1 != t2 {
+			return t1 < t2
+		}
+	}
+	switch k1.Kind() {
+	case reflect.Bool:
+		return !k1.Bool() && k2.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return k1.Int() < k2.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return k1.Uint() < k2.Uint()
+	case reflect.Float32, reflect.Float64:
+		return k1.Float() < k2.Float()
+	case reflect.String:
+		return k1.String() < k2.String()
+	}
+	return anyString(k1) < anyString(k2)
+}
+
+// block writes n entries, each written by entry and the steps it
+// schedules, between braces. Each entry goes on a line of its own, unless
+// DumpInline is set and they all fit on the current line.
+func (d *dumpState) block(n int, entry func(i int)) {
+	if n == 0 {
+		d.printf("{}")
+		return
+	}
+	if d.opts.beyondMaxDepth(len(d.path)) && !d.opts.dumpGoSyntax {
+		d.printf("<max depth>")
+		return
+	}
+	if d.opts.dumpInline && !d.inline && d.tryInline(n, entry) {
+		return
+	}
+	d.printf("{")
+	d.depth++
+	d.each(n, func(i int) {
+		if !d.inline {
+			d.newline()
+		} else if i > 0 {
+			d.printf(", ")
+		}
+		entry(i)
+		if !d.inline {
+			d.then(func() { d.printf(",") })
+		}
+	}, func() {
+		d.depth--
+		if !d.inline {
+			d.newline()
+		}
+		d.printf("}")
+	})
+}
+
+// tryInline writes the block on the current line if it fits within the
+// width, leaving room for the comma after it, and reports whether it did.
+func (d *dumpState) tryInline(n int, entry func(i int)) bool {
+	w, col, decls := d.w, d.col, len(d.decls)
+	buf := &bytes.Buffer{}
+	d.w, d.inline, d.overflow = buf, true, false
+	steps := len(d.todo)
+	d.block(n, entry)
+	d.run(steps)
+	d.w, d.inline, d.col = w, false, col
+	if d.overflow {
+		d.overflow = false
+		d.decls = d.decls[:decls]
+		return false
+	}
+	d.printf("%s", buf)
+	return true
+}
+
+// valueString returns the string to show for v in place of its contents:
+// the result of its registered formatter, if there is one, an RFC 3339
+// timestamp or a duration like 1h23m0s for times and durations, unless
+// RawTimes is given, or else the result of its Error or String method,
+// unless DumpNoMethods is given.
+func (d *dumpState) valueString(v reflect.Value) (string, bool) {
+	if s, ok := formatDumpValue(v); ok {
+		return s, true
+	}
+	switch v.Type() {
+	case timeType, durationType:
+		if d.opts.rawTimes {
+			return "", false
+		}
+		if !v.CanInterface() {
+			break
+		}
+		if t, ok := v.Interface().(time.Time); ok {
+			return t.Format(time.RFC3339Nano), true
+		}
+		return time.Duration(v.Int()).String(), true
+	}
+	if v.Kind() == reflect.Ptr && !v.IsNil() && !d.opts.rawTimes {
+		switch v.Type().Elem() {
+		case timeType, durationType:
+			// Followed, to be written as above, rather than by the String
+			// method of the pointer.
+			return "", false
+		}
+	}
+	if d.opts.dumpNoMethods {
+		return "", false
+	}
+	return methodString(v)
+}
+
+// methodString returns the result of v's Error or String method, if it
+// has one and can be called. Methods that panic, as they may on a nil
+// receiver, are treated as missing.
+func methodString(v reflect.Value) (s string, ok bool) {
+	if !v.IsValid() || !v.CanInterface() {
+		return "", false
+	}
+	x := v.Interface()
+	if v.Kind() != reflect.Ptr && v.CanAddr() {
+		// Methods with pointer receivers are only in the method set of
+		// the pointer.
+		switch p := v.Addr().Interface(); p.(type) {
+		case error, fmt.Stringer:
+			x = p
+		}
+	}
+	defer func() {
+		if recover() != nil {
+			s, ok = "", false
+		}
+	}()
+	switch x := x.(type) {
+	case error:
+		return x.Error(), true
+	case fmt.Stringer:
+		return x.String(), true
+	}
+	return "", false
+}
@@ -0,0 +1,84 @@
+package debugtools
+
+import (
+	"io"
+	"os"
+	"strconv"
+)
+
+// ColorMode says whether Dump colors its output.
+type ColorMode int
+
+const (
+	// ColorAuto colors the output when it is written to a terminal and the
+	// NO_COLOR environment variable is unset. This is the default.
+	ColorAuto ColorMode = iota
+	// ColorAlways colors the output wherever it is written.
+	ColorAlways
+	// ColorNever never colors the output.
+	ColorNever
+)
+
+func (m ColorMode) String() string {
+	switch m {
+	case ColorAuto:
+		return "ColorAuto"
+	case ColorAlways:
+		return "ColorAlways"
+	case ColorNever:
+		return "ColorNever"
+	}
+	return "ColorMode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// DumpColor sets whether Dump colors type names, field names, strings,
+// numbers and nil values with ANSI escape sequences. Output in Go syntax is
+// never colored.
+func DumpColor(m ColorMode) Option {
+	return func(o *options) {
+		o.dumpColor = m
+	}
+}
+
+// The colors used by Dump.
+const (
+	colorType   = "\x1b[36m"
+	colorField  = "\x1b[33m"
+	colorString = "\x1b[32m"
+	colorNumber = "\x1b[35m"
+	colorNil    = "\x1b[31m"
+	colorReset  = "\x1b[0m"
+)
+
+// useColor reports whether a dump written to w should be colored.
+func useColor(w io.Writer, m ColorMode) bool {
+	switch m {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	f, ok := w.(*os.File)
+	return ok && isTerminal(f)
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// paint writes like printf, in color if the dump is colored. The escape
+// sequences don't count towards the width of the line.
+func (d *dumpState) paint(color, format string, vals ...interface{}) {
+	if !d.color {
+		d.printf(format, vals...)
+		return
+	}
+	io.WriteString(d.w, color)
+	d.printf(format, vals...)
+	io.WriteString(d.w, colorReset)
+}
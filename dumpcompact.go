@@ -0,0 +1,228 @@
+package debugtools
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DumpCompact makes Dump write v on a single line, in a dense form like
+// that of fmt's %+v, for embedding in log lines:
+//
+//	&main.Order{ID:7 Items:{"pen" "paper"} Password:<redacted> Next:<cycle>}
+//
+// Types are named only where they don't follow from the enclosing value's
+// type: at the top, and for values held in interfaces. As in the other
+// dump modes, cycles are cut off, redacted fields are shown as <redacted>,
+// and formatters, Error and String methods and the dump options are
+// honored. Values shown by their methods are quoted if they span lines.
+// The output is never colored, and Sdump returns it without the final line
+// break.
+func DumpCompact() Option {
+	return func(o *options) {
+		o.dumpCompact = true
+	}
+}
+
+// compactValue writes v in compact form. typed is whether v's type follows
+// from that of the enclosing value, so that it needn't be named.
+func (d *dumpState) compactValue(v reflect.Value, typed bool) {
+	if d.redacted("") {
+		d.printf("<redacted>")
+		return
+	}
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			d.printf("nil")
+			return
+		}
+		v, typed = v.Elem(), false
+	}
+	if s, ok := d.valueString(v); ok {
+		if strings.ContainsRune(s, '\n') {
+			s = strconv.Quote(s)
+		}
+		d.printf("%s", s)
+		return
+	}
+	t := v.Type()
+	switch v.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128, reflect.String:
+		lit := compactLiteral(v, d.opts.dumpTruncate)
+		if typed || isDefaultType(t) {
+			d.printf("%s", lit)
+		} else {
+			d.printf("%s(%s)", t, lit)
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			d.compactNil(t, typed)
+			return
+		}
+		key := visit{a1: v.Pointer(), typ: t}
+		if d.visited[key] {
+			d.printf("<cycle>")
+			return
+		}
+		d.visited[key] = true
+		d.printf("&")
+		d.compactValue(v.Elem(), typed)
+		d.then(func() { delete(d.visited, key) })
+	case reflect.Map:
+		if v.IsNil() {
+			d.compactNil(t, typed)
+			return
+		}
+		key := visit{a1: v.Pointer(), typ: t}
+		if d.visited[key] {
+			d.printf("<cycle>")
+			return
+		}
+		d.visited[key] = true
+		d.compactComposite(v, typed)
+		d.then(func() { delete(d.visited, key) })
+	case reflect.Slice:
+		if v.IsNil() {
+			d.compactNil(t, typed)
+			return
+		}
+		key := sliceVisit(v)
+		if d.visited[key] {
+			d.printf("<cycle>")
+			return
+		}
+		d.visited[key] = true
+		d.compactComposite(v, typed)
+		d.then(func() { delete(d.visited, key) })
+	case reflect.Array, reflect.Struct:
+		d.compactComposite(v, typed)
+	default:
+		if v.IsNil() {
+			d.compactNil(t, typed)
+		} else {
+			d.printf("%s(%#x)", t, v.Pointer())
+		}
+	}
+}
+
+// compactNil writes nil, converted to t unless typed.
+func (d *dumpState) compactNil(t reflect.Type, typed bool) {
+	if typed {
+		d.printf("nil")
+	} else {
+		d.printf("%s(nil)", t)
+	}
+}
+
+// compactComposite writes the entries of a struct, array, slice or map
+// between braces, separated by spaces, preceded by its type unless typed.
+func (d *dumpState) compactComposite(v reflect.Value, typed bool) {
+	if !typed {
+		d.printf("%s", v.Type())
+	}
+	if d.opts.beyondMaxDepth(len(d.path)) {
+		d.printf("{<max depth>}")
+		return
+	}
+	d.printf("{")
+	sep := func(i int) {
+		if i > 0 {
+			d.printf(" ")
+		}
+	}
+	end := func() {
+		d.printf("}")
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		v = d.addressable(v)
+		t := v.Type()
+		var shown []int
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if (f.IsExported() || d.opts.dumpUnexported) && !d.skip("."+f.Name) {
+				shown = append(shown, i)
+			}
+		}
+		d.each(len(shown), func(i int) {
+			f := t.Field(shown[i])
+			sep(i)
+			d.printf("%s:", f.Name)
+			if hasTagFlag(f, dumpTagKey, "redact") {
+				d.printf("<redacted>")
+				return
+			}
+			d.pushStep("." + f.Name)
+			d.compactValue(d.field(v, shown[i]), true)
+			d.then(d.popStep)
+		}, end)
+	case reflect.Array, reflect.Slice:
+		idx := d.shownIndexes(v.Len())
+		n := d.opts.dumpTruncate
+		if v.Type().Elem().Kind() != reflect.Uint8 || n <= 0 || len(idx) <= 2*n {
+			d.each(len(idx), func(i int) {
+				sep(i)
+				d.compactElement(v, idx, i)
+			}, end)
+			return
+		}
+		// Only the first and last n bytes, as in Dump.
+		d.each(2*n, func(i int) {
+			if i == n {
+				d.printf(" … (%d more)", len(idx)-2*n)
+			}
+			if i >= n {
+				i += len(idx) - 2*n
+			}
+			sep(i)
+			d.compactElement(v, idx, i)
+		}, end)
+	case reflect.Map:
+		keys := d.shownKeys(sortedMapKeys(v))
+		d.each(len(keys), func(i int) {
+			sep(i)
+			d.pushStep("[" + anyString(keys[i]) + "]")
+			d.compactValue(keys[i], true)
+			d.then(func() {
+				d.printf(":")
+				d.compactValue(v.MapIndex(keys[i]), true)
+				d.then(d.popStep)
+			})
+		}, end)
+	}
+}
+
+// compactElement writes the element of v at idx[i], indexed if some
+// elements are left out.
+func (d *dumpState) compactElement(v reflect.Value, idx []int, i int) {
+	d.pushStep("[" + strconv.Itoa(idx[i]) + "]")
+	if len(idx) < v.Len() {
+		d.printf("%d:", idx[i])
+	}
+	d.compactValue(v.Index(idx[i]), true)
+	d.then(d.popStep)
+}
+
+// compactLiteral returns the value of basic kind v as written in compact
+// form: strings quoted and shortened to their first and last n bytes if
+// they are longer than 2n, and numbers as strconv formats them.
+func compactLiteral(v reflect.Value, n int) string {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.String:
+		if head, tail, ok := truncateString(v.String(), n); ok {
+			return strconv.Quote(head) + "…" + strconv.Quote(tail)
+		}
+		return strconv.Quote(v.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+	}
+	return strconv.FormatComplex(v.Complex(), 'g', -1, v.Type().Bits())
+}
@@ -0,0 +1,23 @@
+package debugtools
+
+import (
+	"bytes"
+
+	"github.com/pib/go-debugtools/textdiff"
+)
+
+// DumpDiff dumps a and b as Sdump would and returns a line diff of the two
+// dumps in the style of diff -u, or the empty string if they are the same.
+// It is a quick way to see how two values differ when the structured
+// report of Diff is more than is needed. The dumps are never colored and
+// never show addresses, so that only differences in the values show up.
+// WithTextContext sets the number of lines of context.
+func DumpDiff(a, b interface{}, opts ...Option) string {
+	o := newOptions(opts)
+	o.dumpColor = ColorNever
+	o.dumpAddresses = false
+	var da, db bytes.Buffer
+	dumpValue(&da, a, o)
+	dumpValue(&db, b, o)
+	return textdiff.UnifiedContext(da.String(), db.String(), o.textContext)
+}
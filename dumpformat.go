@@ -0,0 +1,52 @@
+package debugtools
+
+import (
+	"reflect"
+	"sync"
+)
+
+var dumpFormatters struct {
+	sync.RWMutex
+	m map[reflect.Type]func(interface{}) string
+}
+
+// RegisterDumpFormatter makes Dump show values of type t as the string
+// format returns for them, rather than by their contents or methods, so
+// that types such as UUIDs, decimals or protobuf timestamps appear as the
+// value they represent. format is called with a value of type t. A later
+// registration for the same type replaces an earlier one, and a nil format
+// removes it. Formatters are not used with DumpGoSyntax, whose output
+// must remain valid Go.
+func RegisterDumpFormatter(t reflect.Type, format func(v interface{}) string) {
+	dumpFormatters.Lock()
+	defer dumpFormatters.Unlock()
+	if format == nil {
+		delete(dumpFormatters.m, t)
+		return
+	}
+	if dumpFormatters.m == nil {
+		dumpFormatters.m = make(map[reflect.Type]func(interface{}) string)
+	}
+	dumpFormatters.m[t] = format
+}
+
+// formatDumpValue returns the result of the formatter registered for the
+// type of v, if there is one and v can be passed to it. A formatter that
+// panics is treated as missing.
+func formatDumpValue(v reflect.Value) (s string, ok bool) {
+	if !v.IsValid() || !v.CanInterface() {
+		return "", false
+	}
+	dumpFormatters.RLock()
+	format := dumpFormatters.m[v.Type()]
+	dumpFormatters.RUnlock()
+	if format == nil {
+		return "", false
+	}
+	defer func() {
+		if recover() != nil {
+			s, ok = "", false
+		}
+	}()
+	return format(v.Interface()), true
+}
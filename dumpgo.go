@@ -0,0 +1,293 @@
+package debugtools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DumpGoSyntax makes Dump write values as Go composite literals, so that a
+// value captured at run time can be pasted into a test as a fixture:
+//
+//	p1 := 3
+//	&main.Order{
+//		ID: 7,
+//		Items: []string{
+//			"pen",
+//		},
+//		Count: &p1,
+//	}
+//
+// Pointers to values that have no literal syntax of their own, such as
+// *int, are written as the address of a helper variable declared before
+// the literal. Zero-valued struct fields are left out, as are redacted
+// ones and unexported ones without DumpUnexported, and channels,
+// functions and pointers back to a value already being written are
+// written as nil with a comment. Types are named as reflect names them,
+// qualified by package name, so the qualifier must be dropped when pasting
+// into a file in the same package. Unlike other dumps, the literal is built
+// in memory before it is written, since the helper variables it uses have
+// to be declared first.
+func DumpGoSyntax() Option {
+	return func(o *options) {
+		o.dumpGoSyntax = true
+	}
+}
+
+// goDump writes v in Go syntax, preceded by the declarations of the helper
+// variables it needs.
+func (d *dumpState) goDump(v interface{}) {
+	w := d.w
+	if v == nil {
+		io.WriteString(w, "nil\n")
+		return
+	}
+	buf := &bytes.Buffer{}
+	d.w = buf
+	d.goValue(reflect.ValueOf(v), false)
+	d.run(0)
+	for _, decl := range d.decls {
+		io.WriteString(w, decl+"\n")
+	}
+	buf.WriteTo(w)
+	io.WriteString(w, "\n")
+}
+
+// goValue writes v as a Go expression. typed is whether the type of the
+// expression is given by its context, as it is for the elements of
+// composite literals, so that constants and nil need no conversion.
+func (d *dumpState) goValue(v reflect.Value, typed bool) {
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			d.printf("nil")
+			return
+		}
+		v, typed = v.Elem(), false
+	}
+	t := v.Type()
+	if d.goTimeLiteral(v) {
+		d.printf("%s", goTime(v.Interface().(time.Time)))
+		return
+	}
+	switch v.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		lit, convert := goLiteral(v)
+		if convert || !typed && !isDefaultType(t) {
+			d.printf("%s(%s)", t, lit)
+		} else {
+			d.printf("%s", lit)
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			d.goNil(t, typed, "")
+			return
+		}
+		key := visit{a1: v.Pointer(), typ: t}
+		if d.visited[key] {
+			d.goNil(t, typed, "cycle")
+			return
+		}
+		d.visited[key] = true
+		switch e := v.Elem(); e.Kind() {
+		case reflect.Struct, reflect.Array, reflect.Slice, reflect.Map:
+			if d.goTimeLiteral(e) {
+				// A call can't have its address taken either.
+				d.printf("&%s", d.goHelper(e))
+			} else {
+				d.printf("&")
+				d.goComposite(e)
+			}
+		default:
+			d.printf("&%s", d.goHelper(e))
+		}
+		d.then(func() { delete(d.visited, key) })
+	case reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			d.goNil(t, typed, "")
+			return
+		}
+		key := visit{a1: v.Pointer(), typ: t}
+		if v.Kind() == reflect.Slice {
+			key = sliceVisit(v)
+		}
+		if d.visited[key] {
+			d.goNil(t, typed, "cycle")
+			return
+		}
+		d.visited[key] = true
+		d.goComposite(v)
+		d.then(func() { delete(d.visited, key) })
+	case reflect.Array, reflect.Struct:
+		d.goComposite(v)
+	default:
+		if v.IsNil() {
+			d.goNil(t, typed, "")
+		} else {
+			d.goNil(t, typed, t.String())
+		}
+	}
+}
+
+// goTimeLiteral reports whether v is a time.Time written as a call to
+// time.Date rather than as a composite literal.
+func (d *dumpState) goTimeLiteral(v reflect.Value) bool {
+	return v.Type() == timeType && v.CanInterface() && !d.opts.rawTimes
+}
+
+// goNil writes nil, converted to t unless typed, followed by comment if
+// there is one.
+func (d *dumpState) goNil(t reflect.Type, typed bool, comment string) {
+	if typed {
+		d.printf("nil")
+	} else {
+		d.printf("(%s)(nil)", t)
+	}
+	if comment != "" {
+		d.printf(" /* %s */", comment)
+	}
+}
+
+// goComposite writes the composite literal for a struct, array, slice or
+// map.
+func (d *dumpState) goComposite(v reflect.Value) {
+	d.printf("%s", v.Type())
+	switch v.Kind() {
+	case reflect.Struct:
+		v = d.addressable(v)
+		t := v.Type()
+		var shown []int
+		for i := 0; i < t.NumField(); i++ {
+			if (t.Field(i).IsExported() || d.opts.dumpUnexported) && !v.Field(i).IsZero() &&
+				!hasTagFlag(t.Field(i), dumpTagKey, "redact") && !d.skip("."+t.Field(i).Name) &&
+				!d.redacted("."+t.Field(i).Name) {
+				shown = append(shown, i)
+			}
+		}
+		d.block(len(shown), func(i int) {
+			name := t.Field(shown[i]).Name
+			d.pushStep("." + name)
+			d.printf("%s: ", name)
+			d.goValue(d.field(v, shown[i]), true)
+			d.then(d.popStep)
+		})
+	case reflect.Array, reflect.Slice:
+		idx := d.shownIndexes(v.Len())
+		d.block(len(idx), func(i int) {
+			d.pushStep("[" + strconv.Itoa(idx[i]) + "]")
+			if len(idx) < v.Len() {
+				// Index the elements, since some are left out.
+				d.printf("%d: ", idx[i])
+			}
+			d.goValue(v.Index(idx[i]), true)
+			d.then(d.popStep)
+		})
+	case reflect.Map:
+		keys := d.shownKeys(sortedMapKeys(v))
+		d.block(len(keys), func(i int) {
+			d.pushStep("[" + anyString(keys[i]) + "]")
+			d.goValue(keys[i], true)
+			d.then(func() {
+				d.printf(": ")
+				d.goValue(v.MapIndex(keys[i]), true)
+				d.then(d.popStep)
+			})
+		})
+	}
+}
+
+// goHelper declares a helper variable holding v and returns its name.
+func (d *dumpState) goHelper(v reflect.Value) string {
+	w, depth, col, inline, overflow := d.w, d.depth, d.col, d.inline, d.overflow
+	buf := &bytes.Buffer{}
+	d.w, d.depth, d.col, d.inline, d.overflow = buf, 0, 0, false, false
+	steps := len(d.todo)
+	d.goValue(v, false)
+	d.run(steps)
+	d.w, d.depth, d.col, d.inline, d.overflow = w, depth, col, inline, overflow
+	name := "p" + strconv.Itoa(len(d.decls)+1)
+	d.decls = append(d.decls, name+" := "+buf.String())
+	return name
+}
+
+// goLiteral returns the Go literal for a value of basic kind, and whether
+// it must be converted to v's type even where that type is given by the
+// context, as for math.Inf(1) assigned to a float32.
+func goLiteral(v reflect.Value) (lit string, convert bool) {
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), false
+	case reflect.String:
+		return strconv.Quote(v.String()), false
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), false
+	case reflect.Float32, reflect.Float64:
+		return goFloat(v.Float(), v.Type().Bits())
+	}
+	c := v.Complex()
+	bits := v.Type().Bits() / 2
+	re, _ := goFloat(real(c), 64)
+	im, _ := goFloat(imag(c), 64)
+	if strings.HasPrefix(re, "math.") || strings.HasPrefix(im, "math.") {
+		// complex of two float64 values is a complex128.
+		return "complex(" + re + ", " + im + ")", bits != 64
+	}
+	re, _ = goFloat(real(c), bits)
+	im, _ = goFloat(imag(c), bits)
+	if !strings.HasPrefix(im, "-") {
+		im = "+" + im
+	}
+	return "(" + re + im + "i)", false
+}
+
+func goFloat(f float64, bits int) (lit string, convert bool) {
+	switch {
+	case math.IsNaN(f):
+		return "math.NaN()", bits != 64
+	case math.IsInf(f, 1):
+		return "math.Inf(1)", bits != 64
+	case math.IsInf(f, -1):
+		return "math.Inf(-1)", bits != 64
+	}
+	lit = strconv.FormatFloat(f, 'g', -1, bits)
+	if !strings.ContainsAny(lit, ".e") {
+		// Keep the constant untyped float rather than integer.
+		lit += ".0"
+	}
+	return lit, false
+}
+
+// isDefaultType reports whether t is the type an untyped constant of its
+// kind takes when it has no other, so that no conversion is needed.
+func isDefaultType(t reflect.Type) bool {
+	switch t {
+	case reflect.TypeOf(false), reflect.TypeOf(""), reflect.TypeOf(0),
+		reflect.TypeOf(0.0), reflect.TypeOf(0i):
+		return true
+	}
+	return false
+}
+
+// goTime returns a call of time.Date for t.
+func goTime(t time.Time) string {
+	var loc string
+	switch name, offset := t.Zone(); {
+	case t.Location() == time.UTC:
+		loc = "time.UTC"
+	case t.Location() == time.Local:
+		loc = "time.Local"
+	default:
+		loc = "time.FixedZone(" + strconv.Quote(name) + ", " + strconv.Itoa(offset) + ")"
+	}
+	return fmt.Sprintf("time.Date(%d, time.%s, %d, %d, %d, %d, %d, %s)",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
@@ -0,0 +1,229 @@
+package debugtools
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DumpJSON returns v as indented JSON built by reflection, for feeding
+// debug snapshots to jq or a JSON viewer. Unlike encoding/json, it ignores
+// json tags and MarshalJSON methods, and includes unexported fields:
+// structs become objects with a member for every field, named as in Go.
+// Map entries are sorted by key, and keys that aren't strings are
+// formatted, followed by their type if keys of different types would
+// otherwise share a name, as in "1 (int)" and "1 (string)". A pointer or map already written elsewhere in the output,
+// including one leading back into a cycle, is written as a reference to
+// where it was first written, such as {"$ref": "#/Items/0"}, with the
+// location given as a JSON Pointer, as is a slice leading back into a
+// cycle. As in Dump, registered formatters and
+// Error and String methods are used, unless DumpNoMethods is given, and
+// redacted fields and values at paths given to DumpRedactPaths are written
+// as "<redacted>". With DumpMaxDepth, values beyond the depth are written
+// as "<max depth>".
+func DumpJSON(v interface{}, opts ...Option) []byte {
+	o := newOptions(opts)
+	o.dumpUnexported = true
+	j := &jsonDump{
+		d:    &dumpState{opts: o, visited: make(map[visit]bool)},
+		seen: make(map[visit]string),
+	}
+	if v == nil {
+		j.buf.WriteString("null")
+	} else {
+		j.value(reflect.ValueOf(v))
+	}
+	out := &bytes.Buffer{}
+	// The output is valid JSON, so this can't fail.
+	json.Indent(out, j.buf.Bytes(), "", "  ")
+	out.WriteByte('\n')
+	return out.Bytes()
+}
+
+// jsonDump holds the progress of DumpJSON.
+type jsonDump struct {
+	d   *dumpState
+	buf bytes.Buffer
+	enc *json.Encoder
+	// tokens holds the JSON Pointer reference tokens of the current
+	// location.
+	tokens []string
+	// seen holds the location at which each pointer and map was written,
+	// and that of each slice being written, keyed by sliceVisit.
+	seen map[visit]string
+}
+
+func (j *jsonDump) pointer() string {
+	var p strings.Builder
+	p.WriteString("#")
+	for _, t := range j.tokens {
+		p.WriteString("/")
+		p.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(t))
+	}
+	return p.String()
+}
+
+func (j *jsonDump) string(s string) {
+	if j.enc == nil {
+		j.enc = json.NewEncoder(&j.buf)
+		j.enc.SetEscapeHTML(false)
+	}
+	// The newline Encode adds is removed by json.Indent.
+	j.enc.Encode(s)
+}
+
+// member writes the member of an object named token, or if object is false
+// the element of an array at index token, with its value written by write.
+// step is the member's step in the path matched by DumpRedactPaths.
+func (j *jsonDump) member(first bool, token, step string, object bool, write func()) {
+	if !first {
+		j.buf.WriteByte(',')
+	}
+	if object {
+		j.string(token)
+		j.buf.WriteByte(':')
+	}
+	j.tokens = append(j.tokens, token)
+	j.d.pushStep(step)
+	write()
+	j.d.popStep()
+	j.tokens = j.tokens[:len(j.tokens)-1]
+}
+
+// ref writes a reference to the earlier location of v and returns true if
+// it has been written before, and otherwise records the current location.
+func (j *jsonDump) ref(v reflect.Value) bool {
+	key := visit{a1: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key = sliceVisit(v)
+	}
+	if p, ok := j.seen[key]; ok {
+		j.buf.WriteString(`{"$ref":`)
+		j.string(p)
+		j.buf.WriteByte('}')
+		return true
+	}
+	j.seen[key] = j.pointer()
+	return false
+}
+
+func (j *jsonDump) value(v reflect.Value) {
+	if j.d.redacted("") {
+		j.string("<redacted>")
+		return
+	}
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			j.buf.WriteString("null")
+			return
+		}
+		v = v.Elem()
+	}
+	if s, ok := j.d.valueString(v); ok {
+		j.string(s)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		j.buf.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		j.buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		j.buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			// JSON has no representation for these.
+			j.string(strconv.FormatFloat(f, 'g', -1, 64))
+		} else {
+			j.buf.WriteString(strconv.FormatFloat(f, 'g', -1, v.Type().Bits()))
+		}
+	case reflect.Complex64, reflect.Complex128:
+		j.string(strconv.FormatComplex(v.Complex(), 'g', -1, v.Type().Bits()))
+	case reflect.String:
+		j.string(truncatedString(v.String(), j.d.opts.dumpTruncate))
+	case reflect.Ptr:
+		if v.IsNil() {
+			j.buf.WriteString("null")
+		} else if !j.ref(v) {
+			j.value(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			j.buf.WriteString("null")
+			return
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// As encoding/json does.
+			b := make([]byte, v.Len())
+			for i := range b {
+				b[i] = byte(v.Index(i).Uint())
+			}
+			j.string(base64.StdEncoding.EncodeToString(b))
+			return
+		}
+		if j.d.opts.beyondMaxDepth(len(j.tokens)) {
+			j.string("<max depth>")
+			return
+		}
+		if v.Kind() == reflect.Slice {
+			if j.ref(v) {
+				return
+			}
+			// Slices sharing an array elsewhere are written in full.
+			defer delete(j.seen, sliceVisit(v))
+		}
+		j.buf.WriteByte('[')
+		for n, i := range j.d.shownIndexes(v.Len()) {
+			j.member(n == 0, strconv.Itoa(i), "["+strconv.Itoa(i)+"]", false, func() { j.value(v.Index(i)) })
+		}
+		j.buf.WriteByte(']')
+	case reflect.Map:
+		if v.IsNil() {
+			j.buf.WriteString("null")
+			return
+		}
+		if j.d.opts.beyondMaxDepth(len(j.tokens)) {
+			j.string("<max depth>")
+			return
+		}
+		if j.ref(v) {
+			return
+		}
+		j.buf.WriteByte('{')
+		keys := j.d.shownKeys(sortedMapKeys(v))
+		for i, name := range mapKeyNames(keys) {
+			j.member(i == 0, name, "["+anyString(keys[i])+"]", true, func() { j.value(v.MapIndex(keys[i])) })
+		}
+		j.buf.WriteByte('}')
+	case reflect.Struct:
+		if j.d.opts.beyondMaxDepth(len(j.tokens)) {
+			j.string("<max depth>")
+			return
+		}
+		v = j.d.addressable(v)
+		t := v.Type()
+		j.buf.WriteByte('{')
+		shown := 0
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if j.d.skip("." + f.Name) {
+				continue
+			}
+			shown++
+			j.member(shown == 1, f.Name, "."+f.Name, true, func() {
+				if hasTagFlag(f, dumpTagKey, "redact") {
+					j.string("<redacted>")
+				} else {
+					j.value(j.d.field(v, i))
+				}
+			})
+		}
+		j.buf.WriteByte('}')
+	default:
+		j.buf.WriteString("null")
+	}
+}
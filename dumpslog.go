@@ -0,0 +1,204 @@
+package debugtools
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// DumpAttrs returns v as structured logging attributes: the fields of a
+// struct, the entries of a map, or the elements of a slice or array, keyed
+// by field name, key or index, with nested composites as groups. Any other
+// value is returned as a single attribute with the key "value". As in
+// Dump, pointers and interfaces are followed, cycles are cut off, and
+// registered formatters, Error and String methods, redaction and the dump
+// options are honored.
+func DumpAttrs(v interface{}, opts ...Option) []slog.Attr {
+	d := &dumpState{w: io.Discard, opts: newOptions(opts), visited: make(map[visit]bool)}
+	if v == nil {
+		return []slog.Attr{slog.Any("value", nil)}
+	}
+	val := d.attrValue(reflect.ValueOf(v))
+	if val.Kind() != slog.KindGroup {
+		return []slog.Attr{{Key: "value", Value: val}}
+	}
+	return val.Group()
+}
+
+// LogValue returns a slog.LogValuer that logs v as the group of attributes
+// DumpAttrs returns for it, so that a value can be passed to a Logger as
+// nested groups rather than as a pre-rendered string:
+//
+//	logger.Info("order placed", "order", debugtools.LogValue(order))
+//
+// The attributes are built when the record is handled, so v should not be
+// changed in the meantime.
+func LogValue(v interface{}, opts ...Option) slog.LogValuer {
+	return dumpLogValuer{v: v, opts: opts}
+}
+
+type dumpLogValuer struct {
+	v    interface{}
+	opts []Option
+}
+
+func (l dumpLogValuer) LogValue() slog.Value {
+	return slog.GroupValue(DumpAttrs(l.v, l.opts...)...)
+}
+
+// childAttr returns v, the child of the current value at step, as a
+// slog.Value.
+func (d *dumpState) childAttr(step string, v reflect.Value) slog.Value {
+	d.pushStep(step)
+	defer d.popStep()
+	return d.attrValue(v)
+}
+
+// attrValue returns v as a slog.Value.
+func (d *dumpState) attrValue(v reflect.Value) slog.Value {
+	if d.redacted("") {
+		return slog.StringValue("<redacted>")
+	}
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return slog.AnyValue(nil)
+		}
+		v = v.Elem()
+	}
+	switch v.Type() {
+	case timeType:
+		if v.CanInterface() {
+			return slog.TimeValue(v.Interface().(time.Time))
+		}
+	case durationType:
+		return slog.DurationValue(time.Duration(v.Int()))
+	}
+	if s, ok := d.valueString(v); ok {
+		return slog.StringValue(s)
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return slog.BoolValue(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return slog.Int64Value(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return slog.Uint64Value(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return slog.Float64Value(v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		return slog.StringValue(strconv.FormatComplex(v.Complex(), 'g', -1, v.Type().Bits()))
+	case reflect.String:
+		return slog.StringValue(truncatedString(v.String(), d.opts.dumpTruncate))
+	case reflect.Ptr, reflect.Map:
+		if v.IsNil() {
+			return slog.AnyValue(nil)
+		}
+		key := visit{a1: v.Pointer(), typ: v.Type()}
+		if d.visited[key] {
+			return slog.StringValue("<cycle>")
+		}
+		d.visited[key] = true
+		defer delete(d.visited, key)
+		if v.Kind() == reflect.Ptr {
+			return d.attrValue(v.Elem())
+		}
+		keys := d.shownKeys(sortedMapKeys(v))
+		attrs := make([]slog.Attr, len(keys))
+		for i, k := range keys {
+			attrs[i] = slog.Attr{Key: attrKey(k), Value: d.childAttr("["+anyString(k)+"]", v.MapIndex(k))}
+		}
+		return slog.GroupValue(attrs...)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return slog.AnyValue(nil)
+		}
+		if v.Kind() == reflect.Slice {
+			key := sliceVisit(v)
+			if d.visited[key] {
+				return slog.StringValue("<cycle>")
+			}
+			d.visited[key] = true
+			defer delete(d.visited, key)
+		}
+		idx := d.shownIndexes(v.Len())
+		attrs := make([]slog.Attr, len(idx))
+		for n, i := range idx {
+			attrs[n] = slog.Attr{Key: strconv.Itoa(i), Value: d.childAttr("["+strconv.Itoa(i)+"]", v.Index(i))}
+		}
+		return slog.GroupValue(attrs...)
+	case reflect.Struct:
+		v = d.addressable(v)
+		t := v.Type()
+		var attrs []slog.Attr
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() && !d.opts.dumpUnexported || d.skip("."+f.Name) {
+				continue
+			}
+			if hasTagFlag(f, dumpTagKey, "redact") {
+				attrs = append(attrs, slog.String(f.Name, "<redacted>"))
+				continue
+			}
+			attrs = append(attrs, slog.Attr{Key: f.Name, Value: d.childAttr("."+f.Name, d.field(v, i))})
+		}
+		return slog.GroupValue(attrs...)
+	}
+	if v.IsNil() {
+		return slog.AnyValue(nil)
+	}
+	return slog.StringValue(v.Type().String())
+}
+
+// mapKeyNames returns the names of the map keys for DumpJSON and DumpYAML,
+// which are those of attrKey, except where keys of different types share
+// one, as 1 and "1" do: those are followed by their type, as in "1 (int)",
+// or are formatted with %#v if that isn't enough.
+func mapKeyNames(keys []reflect.Value) []string {
+	names := make([]string, len(keys))
+	count := make(map[string]int, len(keys))
+	for i, k := range keys {
+		names[i] = attrKey(k)
+		count[names[i]]++
+	}
+	taken := make(map[string]bool, len(keys))
+	for _, name := range names {
+		if count[name] == 1 {
+			taken[name] = true
+		}
+	}
+	for i, k := range keys {
+		if count[names[i]] == 1 {
+			continue
+		}
+		t := k.Type()
+		if k.Kind() == reflect.Interface && !k.IsNil() {
+			t = k.Elem().Type()
+		}
+		name := names[i] + " (" + t.String() + ")"
+		if taken[name] {
+			name = anyString(k)
+		}
+		for n := 2; taken[name]; n++ {
+			name = anyString(k) + " #" + strconv.Itoa(n)
+		}
+		names[i] = name
+		taken[name] = true
+	}
+	return names
+}
+
+// attrKey returns the attribute key for the map key k.
+func attrKey(k reflect.Value) string {
+	if k.Kind() == reflect.Interface && !k.IsNil() {
+		k = k.Elem()
+	}
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	if s, ok := methodString(k); ok {
+		return s
+	}
+	return anyString(k)
+}
@@ -0,0 +1,238 @@
+package debugtools
+
+import (
+	"bytes"
+	"encoding/base64"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DumpYAML returns v as a YAML document built by reflection, which is
+// easier to read than DumpJSON for deeply nested values such as
+// configurations. It takes the same options and follows the same rules as
+// DumpJSON, except that a pointer or map written more than once is given
+// an anchor where it is first written and is written as an alias to it
+// afterwards, and that one leading back into a cycle, like a slice doing
+// so, is written as "<cycle>", as in Dump, since an alias can't refer to
+// a node containing it.
+func DumpYAML(v interface{}, opts ...Option) []byte {
+	o := newOptions(opts)
+	o.dumpUnexported = true
+	y := &yamlDump{
+		d:    &dumpState{opts: o, visited: make(map[visit]bool)},
+		seen: make(map[visit]*yaml.Node),
+	}
+	var n *yaml.Node
+	if v == nil {
+		n = yamlScalar("!!null", "null")
+	} else {
+		n = y.value(reflect.ValueOf(v))
+	}
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	// The document is built from valid nodes, so this can't fail.
+	enc.Encode(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{n}})
+	enc.Close()
+	return buf.Bytes()
+}
+
+// yamlDump holds the progress of DumpYAML.
+type yamlDump struct {
+	d     *dumpState
+	depth int
+	// seen holds the node written for each pointer and map once it is
+	// complete. Those still being written are in d.visited, along with
+	// slices, keyed by sliceVisit.
+	seen    map[visit]*yaml.Node
+	anchors int
+}
+
+// yamlScalar returns a scalar node. Strings that a YAML 1.1 parser would
+// read as something else, such as yes and off, are quoted, since yaml.v3
+// only quotes those that YAML 1.2 would.
+func yamlScalar(tag, value string) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}
+	if tag == "!!str" && yaml11NotString(value) {
+		n.Style = yaml.DoubleQuotedStyle
+	}
+	return n
+}
+
+// yaml11Bools holds the plain scalars YAML 1.1 reads as booleans, beyond
+// true and false.
+var yaml11Bools = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": true, "N": true, "no": true, "No": true, "NO": true,
+	"on": true, "On": true, "ON": true, "off": true, "Off": true, "OFF": true,
+}
+
+// yaml11Sexagesimal matches YAML 1.1's base 60 numbers, such as 1:20.
+var yaml11Sexagesimal = regexp.MustCompile(`^[-+]?[0-9][0-9_]*(:[0-5]?[0-9])+(\.[0-9_]*)?$`)
+
+// yaml11NotString reports whether the plain scalar s isn't a string in
+// YAML 1.1 but is in YAML 1.2.
+func yaml11NotString(s string) bool {
+	return yaml11Bools[s] || yaml11Sexagesimal.MatchString(s)
+}
+
+// alias returns an alias to the node already written for key, anchoring
+// it if it isn't yet, or nil if key hasn't been written.
+func (y *yamlDump) alias(key visit) *yaml.Node {
+	n := y.seen[key]
+	if n == nil {
+		return nil
+	}
+	if n.Anchor == "" {
+		y.anchors++
+		n.Anchor = "p" + strconv.Itoa(y.anchors)
+	}
+	return &yaml.Node{Kind: yaml.AliasNode, Alias: n, Value: n.Anchor}
+}
+
+// composite returns a node of the given kind for v's contents, added by
+// fill, or a placeholder if v is beyond the maximum depth.
+func (y *yamlDump) composite(kind yaml.Kind, fill func(n *yaml.Node)) *yaml.Node {
+	if y.d.opts.beyondMaxDepth(y.depth) {
+		return yamlScalar("!!str", "<max depth>")
+	}
+	n := &yaml.Node{Kind: kind}
+	y.depth++
+	fill(n)
+	y.depth--
+	return n
+}
+
+// child returns the node for v, the child of the current value at step.
+func (y *yamlDump) child(step string, v reflect.Value) *yaml.Node {
+	y.d.pushStep(step)
+	defer y.d.popStep()
+	return y.value(v)
+}
+
+func (y *yamlDump) value(v reflect.Value) *yaml.Node {
+	if y.d.redacted("") {
+		return yamlScalar("!!str", "<redacted>")
+	}
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return yamlScalar("!!null", "null")
+		}
+		v = v.Elem()
+	}
+	if s, ok := y.d.valueString(v); ok {
+		return yamlScalar("!!str", s)
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return yamlScalar("!!bool", strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return yamlScalar("!!int", strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return yamlScalar("!!int", strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			return yamlScalar("!!float", ".nan")
+		case math.IsInf(f, 1):
+			return yamlScalar("!!float", ".inf")
+		case math.IsInf(f, -1):
+			return yamlScalar("!!float", "-.inf")
+		}
+		lit, _ := goFloat(f, v.Type().Bits())
+		return yamlScalar("!!float", lit)
+	case reflect.Complex64, reflect.Complex128:
+		return yamlScalar("!!str", strconv.FormatComplex(v.Complex(), 'g', -1, v.Type().Bits()))
+	case reflect.String:
+		return yamlScalar("!!str", truncatedString(v.String(), y.d.opts.dumpTruncate))
+	case reflect.Ptr:
+		if v.IsNil() {
+			return yamlScalar("!!null", "null")
+		}
+		key := visit{a1: v.Pointer(), typ: v.Type()}
+		if y.d.visited[key] {
+			return yamlScalar("!!str", "<cycle>")
+		}
+		if a := y.alias(key); a != nil {
+			return a
+		}
+		y.d.visited[key] = true
+		n := y.value(v.Elem())
+		delete(y.d.visited, key)
+		if n.Kind == yaml.AliasNode {
+			n = n.Alias
+		}
+		y.seen[key] = n
+		return n
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return yamlScalar("!!null", "null")
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			for i := range b {
+				b[i] = byte(v.Index(i).Uint())
+			}
+			return yamlScalar("!!binary", base64.StdEncoding.EncodeToString(b))
+		}
+		if v.Kind() == reflect.Slice {
+			// Slices sharing an array elsewhere are written in full.
+			key := sliceVisit(v)
+			if y.d.visited[key] {
+				return yamlScalar("!!str", "<cycle>")
+			}
+			y.d.visited[key] = true
+			defer delete(y.d.visited, key)
+		}
+		return y.composite(yaml.SequenceNode, func(n *yaml.Node) {
+			for _, i := range y.d.shownIndexes(v.Len()) {
+				n.Content = append(n.Content, y.child("["+strconv.Itoa(i)+"]", v.Index(i)))
+			}
+		})
+	case reflect.Map:
+		if v.IsNil() {
+			return yamlScalar("!!null", "null")
+		}
+		key := visit{a1: v.Pointer(), typ: v.Type()}
+		if y.d.visited[key] {
+			return yamlScalar("!!str", "<cycle>")
+		}
+		if a := y.alias(key); a != nil {
+			return a
+		}
+		y.d.visited[key] = true
+		defer delete(y.d.visited, key)
+		n := y.composite(yaml.MappingNode, func(n *yaml.Node) {
+			keys := y.d.shownKeys(sortedMapKeys(v))
+			for i, name := range mapKeyNames(keys) {
+				n.Content = append(n.Content, yamlScalar("!!str", name), y.child("["+anyString(keys[i])+"]", v.MapIndex(keys[i])))
+			}
+		})
+		y.seen[key] = n
+		return n
+	case reflect.Struct:
+		v = y.d.addressable(v)
+		t := v.Type()
+		return y.composite(yaml.MappingNode, func(n *yaml.Node) {
+			for i := 0; i < t.NumField(); i++ {
+				f := t.Field(i)
+				if y.d.skip("." + f.Name) {
+					continue
+				}
+				var val *yaml.Node
+				if hasTagFlag(f, dumpTagKey, "redact") {
+					val = yamlScalar("!!str", "<redacted>")
+				} else {
+					val = y.child("."+f.Name, y.d.field(v, i))
+				}
+				n.Content = append(n.Content, yamlScalar("!!str", f.Name), val)
+			}
+		})
+	}
+	return yamlScalar("!!null", "null")
+}
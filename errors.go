@@ -0,0 +1,64 @@
+package debugtools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DiffErrorChains walks the Unwrap chains of err1 and err2 in lockstep and
+// reports, link by link, where the dynamic types or messages diverge. The
+// chains are equal if they have the same length and every pair of links has
+// the same type and the same Error() text.
+//
+// Errors that wrap several errors (Unwrap() []error) are followed through
+// their first wrapped error, with a note in the trace.
+func DiffErrorChains(err1, err2 error) (bool, string) {
+	buf := &bytes.Buffer{}
+	equal := true
+	for i := 0; err1 != nil || err2 != nil; i++ {
+		if err1 == nil || err2 == nil {
+			fmt.Fprintf(buf, "Link %d: %s != %s\n", i, describeErr(err1), describeErr(err2))
+			fmt.Fprintln(buf, "  One of the chains ended, so not equal")
+			return false, string(buf.Bytes())
+		}
+		fmt.Fprintf(buf, "Link %d: %s vs %s\n", i, describeErr(err1), describeErr(err2))
+		if t1, t2 := fmt.Sprintf("%T", err1), fmt.Sprintf("%T", err2); t1 != t2 {
+			fmt.Fprintf(buf, "  Types don't match: %s != %s\n", t1, t2)
+			equal = false
+		}
+		if m1, m2 := err1.Error(), err2.Error(); m1 != m2 {
+			fmt.Fprintf(buf, "  Messages don't match: %q != %q\n", m1, m2)
+			equal = false
+		}
+		err1 = unwrapErr(buf, "Left", err1)
+		err2 = unwrapErr(buf, "Right", err2)
+	}
+	return equal, string(buf.Bytes())
+}
+
+func describeErr(err error) string {
+	if err == nil {
+		return "<end of chain>"
+	}
+	return fmt.Sprintf("%T(%q)", err, err.Error())
+}
+
+// unwrapErr returns the next link in the chain after err, noting in w when
+// err wraps more than one error.
+func unwrapErr(w io.Writer, side string, err error) error {
+	if next := errors.Unwrap(err); next != nil {
+		return next
+	}
+	if multi, ok := err.(interface{ Unwrap() []error }); ok {
+		errs := multi.Unwrap()
+		if len(errs) > 1 {
+			fmt.Fprintf(w, "  %s link wraps %d errors, following the first\n", side, len(errs))
+		}
+		if len(errs) > 0 {
+			return errs[0]
+		}
+	}
+	return nil
+}
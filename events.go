@@ -0,0 +1,148 @@
+package debugtools
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// EventKind identifies the kind of a CompareEvent.
+type EventKind int
+
+const (
+	// EventEnter is sent when the comparison descends into a path.
+	EventEnter EventKind = iota
+	// EventLeaf is sent when the values at a path compare equal.
+	EventLeaf
+	// EventMismatch is sent when the values at a path compare unequal.
+	EventMismatch
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventEnter:
+		return "enter"
+	case EventLeaf:
+		return "leaf"
+	case EventMismatch:
+		return "mismatch"
+	}
+	return "unknown"
+}
+
+// MarshalText encodes k as its String form.
+func (k EventKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText decodes an EventKind from its String form.
+func (k *EventKind) UnmarshalText(b []byte) error {
+	for _, kind := range []EventKind{EventEnter, EventLeaf, EventMismatch} {
+		if string(b) == kind.String() {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("debugtools: invalid EventKind %q", b)
+}
+
+// A CompareEvent is sent by DeepEqualEvents as the comparison proceeds.
+// Message is empty for EventEnter.
+type CompareEvent struct {
+	Kind    EventKind `json:"kind"`
+	Path    string    `json:"path"`
+	Message string    `json:"message,omitempty"`
+}
+
+// eventBufferSize lets the comparison run slightly ahead of the consumer.
+const eventBufferSize = 64
+
+// DeepEqualEvents compares a and b like DeepEqual in a separate goroutine,
+// sending an event on the returned channel for every path entered and
+// every result reached, so that long comparisons can show progress and be
+// consumed incrementally. The channel is closed when the comparison ends.
+//
+// The returned function waits for the comparison to finish and returns its
+// result. Any events not yet received are discarded, so it is safe to call
+// it without draining the channel first.
+func DeepEqualEvents(a, b interface{}, opts ...Option) (<-chan CompareEvent, func() bool) {
+	ch := make(chan CompareEvent, eventBufferSize)
+	done := make(chan bool, 1)
+	opts = append(opts[:len(opts):len(opts)], func(o *options) {
+		o.reporter = teeReporter(o.reporter, &eventReporter{ch: ch})
+	})
+	go func() {
+		defer close(ch)
+		eq, _ := DeepEqual(a, b, opts...)
+		done <- eq
+	}()
+	return ch, func() bool {
+		for range ch {
+		}
+		return <-done
+	}
+}
+
+// eventReporter is a Reporter that sends CompareEvents on a channel.
+type eventReporter struct {
+	ch chan<- CompareEvent
+}
+
+func (r *eventReporter) PushStep(path string) {
+	r.ch <- CompareEvent{Kind: EventEnter, Path: path}
+}
+
+func (r *eventReporter) Report(res Result) {
+	kind := EventLeaf
+	if !res.Equal {
+		kind = EventMismatch
+	}
+	r.ch <- CompareEvent{Kind: kind, Path: res.Path, Message: res.Message}
+}
+
+func (r *eventReporter) PopStep() {}
+
+// An NDJSONReporter is a Reporter that writes a line of JSON for each
+// CompareEvent as it happens, such as
+//
+//	{"kind":"mismatch","path":".Items[3].Price","message":"5 != 7"}
+//
+// so that the progress of long comparisons can be followed with tail -f,
+// filtered with jq, or fed to a log pipeline.
+type NDJSONReporter struct {
+	enc *json.Encoder
+	err error
+}
+
+// NewNDJSONReporter returns an NDJSONReporter writing to w.
+func NewNDJSONReporter(w io.Writer) *NDJSONReporter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &NDJSONReporter{enc: enc}
+}
+
+// Err returns the first error writing to the underlying writer. Events
+// after an error are dropped.
+func (r *NDJSONReporter) Err() error {
+	return r.err
+}
+
+func (r *NDJSONReporter) PushStep(path string) {
+	r.write(CompareEvent{Kind: EventEnter, Path: path})
+}
+
+func (r *NDJSONReporter) Report(res Result) {
+	kind := EventLeaf
+	if !res.Equal {
+		kind = EventMismatch
+	}
+	r.write(CompareEvent{Kind: kind, Path: res.Path, Message: res.Message})
+}
+
+func (r *NDJSONReporter) PopStep() {}
+
+func (r *NDJSONReporter) write(e CompareEvent) {
+	if r.err == nil {
+		r.err = r.enc.Encode(e)
+	}
+}
@@ -0,0 +1,99 @@
+package debugtools
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// A Nondeterministic path is one at which the results of repeated runs of
+// a function differed, as found by FindNondeterminism.
+type Nondeterministic struct {
+	// Path is the path, written as in Result.Path.
+	Path string
+	// Pairs is the number of pairs of runs whose results differed at Path.
+	Pairs int
+	// Runs are the first pair of runs, numbered from 1, that differed at
+	// Path, and Change is how their results differed there, such as
+	// `"a" != "b"`.
+	Runs   [2]int
+	Change string
+}
+
+// FindNondeterminism calls f runs times and compares every pair of the
+// results with Diff and opts, to find the paths whose values depend on
+// something other than f's input, such as the iteration order of a map or
+// the current time, as happens in serializers and ID generators. The paths
+// are returned with those differing in the most pairs first. It is an
+// error for runs to be less than 2, or for the results to be of different
+// types. Comparing every pair takes runs*(runs-1)/2 diffs, so runs should
+// be small, such as 10.
+func FindNondeterminism(runs int, f func() interface{}, opts ...Option) ([]Nondeterministic, error) {
+	if runs < 2 {
+		return nil, errors.New("debugtools: FindNondeterminism needs at least 2 runs")
+	}
+	results := make([]interface{}, runs)
+	for i := range results {
+		results[i] = f()
+	}
+	byPath := make(map[string]*Nondeterministic)
+	for i := 0; i < runs; i++ {
+		for j := i + 1; j < runs; j++ {
+			d, err := Diff(results[i], results[j], opts...)
+			if err != nil {
+				return nil, fmt.Errorf("debugtools: run %d returned %T, but run %d returned %T", j+1, results[j], i+1, results[i])
+			}
+			for _, n := range d.Leaves() {
+				p := byPath[n.Path]
+				if p == nil {
+					p = &Nondeterministic{Path: n.Path, Runs: [2]int{i + 1, j + 1}, Change: leafChange(n)}
+					byPath[n.Path] = p
+				}
+				p.Pairs++
+			}
+		}
+	}
+	found := make([]Nondeterministic, 0, len(byPath))
+	for _, p := range byPath {
+		found = append(found, *p)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Pairs != found[j].Pairs {
+			return found[i].Pairs > found[j].Pairs
+		}
+		return found[i].Path < found[j].Path
+	})
+	return found, nil
+}
+
+// AssertDeterministic checks that f returns equal results over runs calls,
+// as FindNondeterminism does with opts, and reports whether it does. If it
+// doesn't, t fails with each path whose value varied and an example of how
+// it varied:
+//
+//	debugtools.AssertDeterministic(t, 10, func() interface{} {
+//		b, _ := encode(order)
+//		return string(b)
+//	})
+func AssertDeterministic(t testing.TB, runs int, f func() interface{}, opts ...Option) bool {
+	t.Helper()
+	found, err := FindNondeterminism(runs, f, opts...)
+	if err != nil {
+		t.Errorf("%v", err)
+		return false
+	}
+	if len(found) == 0 {
+		return true
+	}
+	pairs := runs * (runs - 1) / 2
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "results differ between runs at %d path(s):", len(found))
+	for _, p := range found {
+		fmt.Fprintf(&sb, "\n%s: %d of %d pairs of runs differ\n\truns %d and %d: %s",
+			displayPath(p.Path), p.Pairs, pairs, p.Runs[0], p.Runs[1], p.Change)
+	}
+	fail(t, opts, sb.String())
+	return false
+}
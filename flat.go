@@ -0,0 +1,71 @@
+package debugtools
+
+import (
+	"bytes"
+	"reflect"
+	"unsafe"
+)
+
+// flatType reports whether values of type t hold no pointers, maps,
+// slices, interfaces, channels or functions, and none compared by their
+// driver.Value, so that they can be compared whole, without walking them.
+// raw reports whether they can moreover be compared as memory: they hold
+// no floats, whose == isn't equality of their bits, no strings, and no
+// padding.
+func flatType(t reflect.Type) (flat, raw bool) {
+	if t.Implements(valuerType) {
+		return false, false
+	}
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true, true
+	case reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128, reflect.String:
+		return true, false
+	case reflect.Array:
+		return flatType(t.Elem())
+	case reflect.Struct:
+		raw = true
+		var size uintptr
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			ff, fr := flatType(f.Type)
+			if !ff {
+				return false, false
+			}
+			raw = raw && fr
+			size += f.Type.Size()
+		}
+		return true, raw && size == t.Size()
+	}
+	return false, false
+}
+
+// flatEqual compares two values of a flat type whole: as memory if raw is
+// set and both are addressable, and otherwise leaf by leaf with ==.
+func flatEqual(v1, v2 reflect.Value, raw bool) bool {
+	if raw && v1.CanAddr() && v2.CanAddr() {
+		n := int(v1.Type().Size())
+		b1 := unsafe.Slice((*byte)(unsafe.Pointer(v1.UnsafeAddr())), n)
+		b2 := unsafe.Slice((*byte)(unsafe.Pointer(v2.UnsafeAddr())), n)
+		return bytes.Equal(b1, b2)
+	}
+	switch v1.Kind() {
+	case reflect.Struct:
+		for i, n := 0, v1.NumField(); i < n; i++ {
+			if !flatEqual(v1.Field(i), v2.Field(i), false) {
+				return false
+			}
+		}
+		return true
+	case reflect.Array:
+		for i := 0; i < v1.Len(); i++ {
+			if !flatEqual(v1.Index(i), v2.Index(i), false) {
+				return false
+			}
+		}
+		return true
+	}
+	return leafEqual(v1, v2)
+}
@@ -0,0 +1,64 @@
+package debugtools
+
+import (
+	"reflect"
+	"strings"
+)
+
+// The functions below are called by the comparators deepequalgen
+// generates, for the values they don't compare themselves, so that they
+// decide and describe differences as DeepEqual and Diff do. Each takes
+// pointers to the values, which keeps their static types, even for
+// interfaces.
+
+// generatedComparer makes the comparisons below, which take no Options.
+var generatedComparer = NewComparer()
+
+// EqualValues reports whether *a and *b are deeply equal, as DeepEqual
+// does, without writing a trace.
+func EqualValues(a, b interface{}) bool {
+	c := generatedComparer
+	eq, _, _ := compareValues(&c.states, reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem(), c.o, false, false)
+	return eq
+}
+
+// EqualJSON reports whether *a and *b are equal as a field tagged
+// `deepequal:"json"` is compared.
+func EqualJSON(a, b *[]byte) bool {
+	return EqualValues(&jsonField{*a}, &jsonField{*b})
+}
+
+// AppendDiff appends the leaves of the DiffTree of *a and *b, found at
+// path, to diffs.
+func AppendDiff(diffs []Difference, path string, a, b interface{}) []Difference {
+	d, err := DiffValues(reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem())
+	if err != nil {
+		return append(diffs, Difference{Path: path, Message: err.Error()})
+	}
+	return appendLeaves(diffs, path, "", d)
+}
+
+// AppendJSONDiff is like AppendDiff for a field tagged `deepequal:"json"`.
+func AppendJSONDiff(diffs []Difference, path string, a, b *[]byte) []Difference {
+	d, err := Diff(&jsonField{*a}, &jsonField{*b})
+	if err != nil {
+		return append(diffs, Difference{Path: path, Message: err.Error()})
+	}
+	return appendLeaves(diffs, path, ".V", d)
+}
+
+// jsonField holds a byte slice to be compared as JSON.
+type jsonField struct {
+	V []byte `deepequal:"json"`
+}
+
+// appendLeaves appends the leaves of d to diffs, with trim removed from
+// the start of their paths and path added in its place.
+func appendLeaves(diffs []Difference, path, trim string, d *DiffTree) []Difference {
+	for _, n := range d.Leaves() {
+		leaf := *n
+		leaf.Path = path + strings.TrimPrefix(leaf.Path, trim)
+		diffs = append(diffs, leaf)
+	}
+	return diffs
+}
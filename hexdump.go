@@ -0,0 +1,19 @@
+package debugtools
+
+import (
+	"encoding/hex"
+	"io"
+)
+
+// NewHexDumper returns a WriteCloser that writes everything written to it
+// to w as a hex dump, in the format of hexdump -C: each line holds the
+// offset, sixteen bytes in hex and the same bytes as ASCII, as in
+//
+//	00000000  47 45 54 20 2f 20 48 54  54 50 2f 31 2e 31 0d 0a  |GET / HTTP/1.1..|
+//
+// Lines are written as soon as they are complete, so payloads can be
+// dumped as they are read from a connection. Close writes the last,
+// partial line; it doesn't close w.
+func NewHexDumper(w io.Writer) io.WriteCloser {
+	return hex.Dumper(w)
+}
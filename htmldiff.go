@@ -0,0 +1,106 @@
+package debugtools
+
+import (
+	"bufio"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/pib/go-debugtools/textdiff"
+)
+
+// HTMLFormatter renders a DiffTree as a standalone HTML page, suitable for
+// attaching to a CI job. Each grouping node is a collapsible subtree, changes
+// are colored by kind, and a search box hides the changes whose path or
+// message doesn't contain the search text.
+type HTMLFormatter struct {
+	// Title is the page title, "Diff" if empty.
+	Title string
+	// InlineStringDiff shows changes to long or multi-line strings as the
+	// new string with the changed characters marked, using
+	// textdiff.HTML, instead of as a message.
+	InlineStringDiff bool
+}
+
+func (f HTMLFormatter) FormatDiff(w io.Writer, d *DiffTree) error {
+	title := f.Title
+	if title == "" {
+		title = "Diff"
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>")
+	bw.WriteString(html.EscapeString(title))
+	bw.WriteString("</title>\n<style>\n" + htmlStyle + "</style>\n</head>\n<body>\n<h1>")
+	bw.WriteString(html.EscapeString(title))
+	bw.WriteString("</h1>\n")
+	if d.Root == nil {
+		bw.WriteString("<p>No differences.</p>\n")
+	} else {
+		bw.WriteString("<input id=\"search\" type=\"search\" placeholder=\"Filter by path or message\">\n<ul>\n")
+		f.writeNode(bw, d.Root)
+		bw.WriteString("</ul>\n<script>\n" + htmlScript + "</script>\n")
+	}
+	bw.WriteString("</body>\n</html>\n")
+	return bw.Flush()
+}
+
+func (f HTMLFormatter) writeNode(w *bufio.Writer, n *Difference) {
+	path := html.EscapeString(displayPath(n.Path))
+	if !n.IsLeaf() {
+		w.WriteString("<li><details open><summary><code>" + path + "</code></summary>\n<ul>\n")
+		for _, c := range n.Children {
+			f.writeNode(w, c)
+		}
+		w.WriteString("</ul>\n</details></li>\n")
+		return
+	}
+	s1, ok1 := n.Left.(string)
+	s2, ok2 := n.Right.(string)
+	if f.InlineStringDiff && ok1 && ok2 && (len(s1) > longString || len(s2) > longString || strings.Contains(s1+s2, "\n")) {
+		w.WriteString("<li class=\"change modified\"><span class=\"mark\">~</span> <code>" + path + "</code>: <pre class=\"inline\">" + textdiff.HTML(s1, s2) + "</pre></li>\n")
+		return
+	}
+	var mark, text string
+	switch n.Kind {
+	case Inserted:
+		mark, text = "+", n.RightText
+	case Deleted:
+		mark, text = "-", n.LeftText
+	default:
+		mark, text = "~", n.Message
+	}
+	w.WriteString("<li class=\"change " + n.Kind.String() + "\"><span class=\"mark\">" + mark + "</span> <code>" + path + "</code>: <pre>" + html.EscapeString(text) + "</pre></li>\n")
+}
+
+const htmlStyle = `body { font-family: sans-serif; margin: 2em; }
+ul { list-style: none; padding-left: 1.5em; }
+summary { cursor: pointer; }
+pre { display: inline; white-space: pre-wrap; margin: 0; }
+.change { padding: 2px 4px; margin: 2px 0; border-radius: 3px; }
+.modified { background: #fff5cc; }
+.inserted { background: #dcffe4; }
+.deleted { background: #ffdce0; }
+.mark { font-family: monospace; font-weight: bold; }
+.inline { display: block; margin: 4px 0 0 1.5em; }
+del { background: #fdb8c0; }
+ins { background: #acf2bd; text-decoration: none; }
+.hidden { display: none; }
+#search { width: 30em; padding: 4px; }
+`
+
+// htmlScript filters the changes by the search text, hiding subtrees that
+// are left with no visible changes.
+const htmlScript = `document.getElementById("search").addEventListener("input", function () {
+  var q = this.value.toLowerCase();
+  document.querySelectorAll("li.change").forEach(function (li) {
+    li.classList.toggle("hidden", q !== "" && li.textContent.toLowerCase().indexOf(q) < 0);
+  });
+  Array.from(document.querySelectorAll("details")).reverse().forEach(function (d) {
+    var visible = d.querySelector("li.change:not(.hidden)") !== null;
+    d.parentElement.classList.toggle("hidden", !visible);
+    if (q !== "" && visible) {
+      d.open = true;
+    }
+  });
+});
+`
@@ -0,0 +1,92 @@
+package debugtools
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/pib/go-debugtools/textdiff"
+)
+
+// A Response is what AssertResponse expects of a recorded HTTP response.
+type Response struct {
+	// Status is the expected status code, or 0 not to check it.
+	Status int
+	// Header holds the headers to check, with their values in order.
+	// Headers not in it aren't checked, and one given with no values is
+	// expected to be absent.
+	Header http.Header
+	// Body is the expected body, or nil not to check it. If it and the
+	// recorded body are both JSON, they are compared as documents, as
+	// DiffJSON does, and otherwise line by line.
+	Body []byte
+}
+
+// AssertResponse checks the response recorded by rec against want, and
+// reports whether it matches. If it doesn't, t fails with only the parts
+// that differ: the status, each mismatched header, and the differences
+// within the body, rather than dumps of both bodies whole:
+//
+//	debugtools.AssertResponse(t, rec, debugtools.Response{
+//		Status: http.StatusOK,
+//		Header: http.Header{"Content-Type": {"application/json"}},
+//		Body:   []byte(`{"id": 7, "name": "pen"}`),
+//	}, debugtools.IgnorePaths("createdAt"))
+//
+// opts are used to compare JSON bodies, so that IgnorePaths and OnlyPaths
+// can leave out parts of a body that change between runs.
+func AssertResponse(t testing.TB, rec *httptest.ResponseRecorder, want Response, opts ...Option) bool {
+	t.Helper()
+	var problems []string
+	if want.Status != 0 && rec.Code != want.Status {
+		problems = append(problems, fmt.Sprintf("status: got %d, want %d", rec.Code, want.Status))
+	}
+	keys := make([]string, 0, len(want.Header))
+	for k := range want.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		got, w := rec.Header().Values(k), want.Header[k]
+		if strings.Join(got, "\x00") != strings.Join(w, "\x00") || len(got) != len(w) {
+			problems = append(problems, fmt.Sprintf("header %s: got %q, want %q", http.CanonicalHeaderKey(k), got, w))
+		}
+	}
+	if want.Body != nil {
+		if diff := bodyDiff(want.Body, rec.Body.Bytes(), opts); diff != "" {
+			problems = append(problems, "body differs from want:\n"+diff)
+		}
+	}
+	if len(problems) == 0 {
+		return true
+	}
+	fail(t, opts, fmt.Sprintf("response differs:\n%s%s", strings.Join(problems, "\n"), artifactsNote(t, opts, func() []artifact {
+		return []artifact{
+			{"want.body", want.Body},
+			{"got.body", rec.Body.Bytes()},
+			{"got.header", []byte(Sdump(rec.Header(), DumpColor(ColorNever)))},
+		}
+	})))
+	return false
+}
+
+// bodyDiff returns the differences between the bodies want and got, or ""
+// if they are equal.
+func bodyDiff(want, got []byte, opts []Option) string {
+	if json.Valid(want) && json.Valid(got) {
+		if d, err := DiffJSON(want, got, opts...); err == nil {
+			if d.Equal() {
+				return ""
+			}
+			return strings.TrimSuffix(d.String(), "\n")
+		}
+	}
+	if string(want) == string(got) {
+		return ""
+	}
+	return strings.TrimSuffix(textdiff.Unified(string(want), string(got)), "\n")
+}
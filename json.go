@@ -0,0 +1,63 @@
+package debugtools
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+)
+
+var (
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+	jsonNumberType = reflect.TypeOf(json.Number(""))
+)
+
+func isBytes(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// jsonEqual compares two byte slices holding JSON documents by parsing them
+// and comparing the results, so that whitespace, key order and the way
+// numbers are written don't matter, as in DiffJSON, and a mismatch is
+// reported by its path within the document. It reports ok=false if either
+// side isn't valid JSON, which leaves the bytes to be compared as they
+// are.
+func (s *deepEqualState) jsonEqual(v1, v2 reflect.Value) (eq, ok bool) {
+	b1, b2 := v1.Bytes(), v2.Bytes()
+	if bytes.Equal(b1, b2) {
+		s.printf("%s == %s\n", b1, b2)
+		return s.report(true, "%s == %s", b1, b2), true
+	}
+	d1, err1 := decodeJSON(b1)
+	d2, err2 := decodeJSON(b2)
+	if err1 != nil || err2 != nil {
+		s.printf("Not both valid JSON, comparing bytes: %v, %v\n", err1, err2)
+		return false, false
+	}
+	s.println("Comparing JSON documents of type:", v1.Type())
+	prev := s.inJSON
+	s.inJSON = true
+	eq = s.deepValueEqual(reflect.ValueOf(&d1).Elem(), reflect.ValueOf(&d2).Elem())
+	s.inJSON = prev
+	return eq, true
+}
+
+// decodeJSON parses a JSON document, keeping numbers as json.Numbers so that
+// no precision is lost. Anything but whitespace after the document is an
+// error, as it is for json.Unmarshal.
+func decodeJSON(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if err := dec.Decode(new(interface{})); err != io.EOF {
+		if err == nil {
+			err = errors.New("more than one top-level value")
+		}
+		return nil, err
+	}
+	return v, nil
+}
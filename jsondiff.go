@@ -0,0 +1,188 @@
+package debugtools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+// DiffJSON parses the JSON documents a and b and compares them
+// structurally, independently of any Go types, so that whitespace and key
+// order don't matter. Each difference - a changed type, a changed value, a
+// missing key or an extra array element - is addressed by its path within
+// the document, such as ".users[2].name". Numbers are compared by value,
+// so 1 and 1.0 are equal. IgnorePaths and OnlyPaths are honored; other
+// options are not. It returns an error if either document isn't valid
+// JSON, including one followed by anything but whitespace.
+func DiffJSON(a, b []byte, opts ...Option) (*DiffTree, error) {
+	d1, err := decodeJSON(a)
+	if err != nil {
+		return nil, fmt.Errorf("debugtools: parsing first JSON document: %w", err)
+	}
+	d2, err := decodeJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("debugtools: parsing second JSON document: %w", err)
+	}
+	j := &jsonDiffer{opts: newOptions(opts)}
+	return &DiffTree{Root: j.diff("", d1, d2)}, nil
+}
+
+type jsonDiffer struct {
+	opts *options
+}
+
+// jsonMissing stands for a value that isn't present on one side.
+type jsonMissing struct{}
+
+func (j *jsonDiffer) diff(path string, v1, v2 interface{}) *Difference {
+	t1, t2 := jsonType(v1), jsonType(v2)
+	switch {
+	case t1 == "missing":
+		return jsonDifference(path, Inserted, fmt.Sprintf("Only in right: %s", jsonText(v2)), v1, v2)
+	case t2 == "missing":
+		return jsonDifference(path, Deleted, fmt.Sprintf("Only in left: %s", jsonText(v1)), v1, v2)
+	case t1 != t2:
+		return jsonDifference(path, Modified, fmt.Sprintf("Type changed from %s to %s: %s != %s", t1, t2, jsonText(v1), jsonText(v2)), v1, v2)
+	}
+	switch x1 := v1.(type) {
+	case map[string]interface{}:
+		return j.diffObjects(path, x1, v2.(map[string]interface{}))
+	case []interface{}:
+		return j.diffArrays(path, x1, v2.([]interface{}))
+	case json.Number:
+		if jsonNumbersEqual(x1, v2.(json.Number)) {
+			return nil
+		}
+	default:
+		if v1 == v2 {
+			return nil
+		}
+	}
+	return jsonDifference(path, Modified, fmt.Sprintf("%s != %s", jsonText(v1), jsonText(v2)), v1, v2)
+}
+
+func (j *jsonDiffer) diffObjects(path string, o1, o2 map[string]interface{}) *Difference {
+	keys := make([]string, 0, len(o1)+len(o2))
+	for k := range o1 {
+		keys = append(keys, k)
+	}
+	for k := range o2 {
+		if _, ok := o1[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	node := &Difference{Path: path}
+	for _, k := range keys {
+		p := path + jsonKeyStep(k)
+		if j.opts.excluded(p) {
+			continue
+		}
+		v1, ok1 := o1[k]
+		v2, ok2 := o2[k]
+		if !ok1 {
+			v1 = jsonMissing{}
+		}
+		if !ok2 {
+			v2 = jsonMissing{}
+		}
+		if d := j.diff(p, v1, v2); d != nil {
+			node.Children = append(node.Children, d)
+		}
+	}
+	if len(node.Children) == 0 {
+		return nil
+	}
+	return node
+}
+
+func (j *jsonDiffer) diffArrays(path string, a1, a2 []interface{}) *Difference {
+	node := &Difference{Path: path}
+	for i := 0; i < len(a1) || i < len(a2); i++ {
+		p := path + "[" + strconv.Itoa(i) + "]"
+		if j.opts.excluded(p) {
+			continue
+		}
+		var d *Difference
+		switch {
+		case i >= len(a1):
+			d = jsonDifference(p, Inserted, fmt.Sprintf("Array length %d != %d, only in right: %s", len(a1), len(a2), jsonText(a2[i])), jsonMissing{}, a2[i])
+		case i >= len(a2):
+			d = jsonDifference(p, Deleted, fmt.Sprintf("Array length %d != %d, only in left: %s", len(a1), len(a2), jsonText(a1[i])), a1[i], jsonMissing{})
+		default:
+			d = j.diff(p, a1[i], a2[i])
+		}
+		if d != nil {
+			node.Children = append(node.Children, d)
+		}
+	}
+	if len(node.Children) == 0 {
+		return nil
+	}
+	return node
+}
+
+func jsonDifference(path string, kind ChangeKind, msg string, v1, v2 interface{}) *Difference {
+	d := &Difference{Path: path, Kind: kind, Message: msg}
+	if _, ok := v1.(jsonMissing); !ok {
+		d.Left, d.LeftText = v1, jsonText(v1)
+	}
+	if _, ok := v2.(jsonMissing); !ok {
+		d.Right, d.RightText = v2, jsonText(v2)
+	}
+	return d
+}
+
+// jsonKeyStep returns the path step for an object key: ".key" if the key
+// is an identifier, or a quoted ["key"] otherwise.
+func jsonKeyStep(k string) string {
+	for i, r := range k {
+		if !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+			return "[" + strconv.Quote(k) + "]"
+		}
+	}
+	if k == "" {
+		return `[""]`
+	}
+	return "." + k
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case jsonMissing:
+		return "missing"
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// jsonNumbersEqual compares two JSON numbers exactly, by value.
+func jsonNumbersEqual(n1, n2 json.Number) bool {
+	if n1 == n2 {
+		return true
+	}
+	r1, ok1 := new(big.Rat).SetString(string(n1))
+	r2, ok2 := new(big.Rat).SetString(string(n2))
+	return ok1 && ok2 && r1.Cmp(r2) == 0
+}
+
+func jsonText(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
@@ -0,0 +1,53 @@
+package debugtools
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+)
+
+// DumpLayout writes the memory layout of typ to standard output: the
+// offset, size and alignment of each field of a struct, the padding the
+// compiler inserts between fields and after the last one, and the total
+// size, so that fields can be reordered to shrink a struct or keep hot
+// fields on one cache line. For example:
+//
+//	main.Order: size 24, align 8
+//	offset  size  align  field
+//	     0     1      1  Paid bool
+//	     1     7         (padding)
+//	     8     8      8  ID int64
+//	    16     1      1  Open bool
+//	    17     7         (padding)
+//	24 bytes, 14 of them padding
+//
+// For a type other than a struct, only its size and alignment are written.
+func DumpLayout(typ reflect.Type) {
+	writeLayout(os.Stdout, typ)
+}
+
+func writeLayout(w io.Writer, t reflect.Type) {
+	fmt.Fprintf(w, "%s: size %d, align %d\n", t, t.Size(), t.Align())
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	fmt.Fprintf(w, "offset  size  align  field\n")
+	var end, padding uintptr
+	pad := func(upTo uintptr) {
+		if upTo > end {
+			fmt.Fprintf(w, "%6d  %4d         (padding)\n", end, upTo-end)
+			padding += upTo - end
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		pad(f.Offset)
+		fmt.Fprintf(w, "%6d  %4d  %5d  %s %s\n", f.Offset, f.Type.Size(), f.Type.Align(), f.Name, f.Type)
+		if e := f.Offset + f.Type.Size(); e > end {
+			end = e
+		}
+	}
+	pad(t.Size())
+	fmt.Fprintf(w, "%d bytes, %d of them padding\n", t.Size(), padding)
+}
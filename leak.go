@@ -0,0 +1,171 @@
+package debugtools
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// leakTimeout is how long NoLeaks waits for goroutines to exit before
+// reporting them as leaked.
+const leakTimeout = time.Second
+
+// NoLeaks records the goroutines running when it is called, at the start
+// of a test, and fails t when the test and its cleanups registered later
+// have finished if any goroutine started since is still running. Leaked
+// goroutines are grouped by where they were created and what they are
+// doing, so that a thousand copies of the same stuck worker are reported
+// once:
+//
+//	func TestServer(t *testing.T) {
+//		debugtools.NoLeaks(t)
+//		...
+//	}
+//
+// To give goroutines that are shutting down time to exit, the check is
+// retried for up to a second before failing. Goroutines started by
+// parallel tests running at the same time can be reported as leaks, so
+// NoLeaks is best used in tests that don't call t.Parallel.
+func NoLeaks(t testing.TB) {
+	t.Helper()
+	before := make(map[int]bool)
+	for _, g := range goroutines() {
+		before[g.id] = true
+	}
+	t.Cleanup(func() {
+		var leaked []goroutine
+		for wait, deadline := time.Millisecond, time.Now().Add(leakTimeout); ; wait *= 2 {
+			leaked = leaked[:0]
+			for _, g := range goroutines() {
+				if !before[g.id] {
+					leaked = append(leaked, g)
+				}
+			}
+			if len(leaked) == 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(wait)
+		}
+		if len(leaked) > 0 {
+			t.Errorf("%s", leakReport(leaked))
+		}
+	})
+}
+
+// A goroutine is one parsed from the output of runtime.Stack.
+type goroutine struct {
+	id    int
+	state string
+	// stack holds the function and location of each frame, innermost
+	// first, without arguments or program counter offsets.
+	stack []string
+	// createdBy is the function and location the goroutine was started at.
+	createdBy string
+}
+
+// goroutines returns the goroutines other than the calling one.
+func goroutines() []goroutine {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	blocks := strings.Split(string(buf), "\n\n")
+	gs := make([]goroutine, 0, len(blocks))
+	// The calling goroutine comes first.
+	for _, b := range blocks[1:] {
+		if g, ok := parseGoroutine(b); ok {
+			gs = append(gs, g)
+		}
+	}
+	return gs
+}
+
+// parseGoroutine parses a block of runtime.Stack output such as
+//
+//	goroutine 7 [chan receive]:
+//	main.worker(0xc000012345)
+//		/src/main.go:20 +0x25
+//	created by main.start in goroutine 1
+//		/src/main.go:12 +0x3d
+func parseGoroutine(block string) (goroutine, bool) {
+	lines := strings.Split(strings.TrimSpace(block), "\n")
+	header := strings.TrimSuffix(strings.TrimPrefix(lines[0], "goroutine "), ":")
+	id, state, ok := strings.Cut(header, " ")
+	if !ok {
+		return goroutine{}, false
+	}
+	// Leave off how long the goroutine has been blocked, such as in
+	// [chan receive, 2 minutes], so that it can be grouped with others.
+	state, _, _ = strings.Cut(strings.Trim(state, "[]"), ",")
+	g := goroutine{state: state}
+	var err error
+	if g.id, err = strconv.Atoi(id); err != nil {
+		return goroutine{}, false
+	}
+	for i := 1; i < len(lines); i += 2 {
+		fn := lines[i]
+		var loc string
+		if i+1 < len(lines) {
+			loc = strings.TrimSpace(lines[i+1])
+			if j := strings.LastIndex(loc, " +0x"); j >= 0 {
+				loc = loc[:j]
+			}
+		}
+		if created, ok := strings.CutPrefix(fn, "created by "); ok {
+			if j := strings.Index(created, " in goroutine "); j >= 0 {
+				created = created[:j]
+			}
+			g.createdBy = created + " at " + loc
+			continue
+		}
+		if j := strings.LastIndexByte(fn, '('); j > 0 {
+			fn = fn[:j]
+		}
+		g.stack = append(g.stack, fn+"\n\t\t"+loc)
+	}
+	return g, true
+}
+
+// leakReport describes the leaked goroutines, grouping those created at
+// the same place with the same stack and state.
+func leakReport(leaked []goroutine) string {
+	type group struct {
+		g     goroutine
+		count int
+	}
+	var groups []*group
+	index := make(map[string]*group)
+	for _, g := range leaked {
+		key := g.state + "\n" + g.createdBy + "\n" + strings.Join(g.stack, "\n")
+		if gr, ok := index[key]; ok {
+			gr.count++
+			continue
+		}
+		gr := &group{g: g, count: 1}
+		index[key] = gr
+		groups = append(groups, gr)
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].count > groups[j].count })
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d goroutine(s) leaked:", len(leaked))
+	for _, gr := range groups {
+		fmt.Fprintf(&sb, "\n\n%d × [%s]", gr.count, gr.g.state)
+		if gr.g.createdBy != "" {
+			fmt.Fprintf(&sb, ", created by %s", gr.g.createdBy)
+		}
+		sb.WriteString(":")
+		for _, f := range gr.g.stack {
+			sb.WriteString("\n\t" + f)
+		}
+	}
+	return sb.String()
+}
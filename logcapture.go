@@ -0,0 +1,202 @@
+package debugtools
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+// A LogRecord is a log record captured by a LogCapture, as a plain value
+// that can be compared with DeepEqual. The time of the record is left out,
+// since it differs from run to run.
+type LogRecord struct {
+	Level   slog.Level
+	Message string
+	// Attrs holds the record's attributes, including those added to the
+	// logger with With, by key, with groups as nested maps, or is nil if
+	// there are none. Values are as slog.Value.Any returns them once
+	// resolved, so integers are int64 and unsigned integers uint64, which
+	// EquateNumericKinds makes equal to other integer kinds.
+	Attrs map[string]interface{}
+}
+
+// A LogCapture collects the records logged through its handler during a
+// test.
+type LogCapture struct {
+	mu      sync.Mutex
+	records []LogRecord
+}
+
+// CaptureLogs makes a LogCapture the destination of the default slog
+// logger, and of the log package, until t finishes, and returns it. Every
+// level is captured. Loggers made by the code under test with a handler of
+// its own can be pointed at the capture with its Handler method.
+//
+//	logs := debugtools.CaptureLogs(t)
+//	serve(req)
+//	logs.Assert(t, []debugtools.LogRecord{
+//		{Level: slog.LevelWarn, Message: "slow request", Attrs: map[string]interface{}{"path": "/"}},
+//	}, debugtools.EquateNumericKinds())
+//
+// Tests capturing logs this way must not run in parallel with each other,
+// since the default logger is shared.
+func CaptureLogs(t testing.TB) *LogCapture {
+	c := &LogCapture{}
+	prev := slog.Default()
+	// Restoring the default slog logger doesn't point the log package back
+	// at its own writer, so its output and flags are restored separately.
+	w, flags := log.Writer(), log.Flags()
+	slog.SetDefault(slog.New(c.Handler()))
+	t.Cleanup(func() {
+		slog.SetDefault(prev)
+		log.SetOutput(w)
+		log.SetFlags(flags)
+	})
+	return c
+}
+
+// Handler returns a slog.Handler adding the records it handles to c.
+func (c *LogCapture) Handler() slog.Handler {
+	return &captureHandler{c: c}
+}
+
+// Records returns the records captured so far, in the order they were
+// logged.
+func (c *LogCapture) Records() []LogRecord {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]LogRecord(nil), c.records...)
+}
+
+// Reset discards the records captured so far.
+func (c *LogCapture) Reset() {
+	c.mu.Lock()
+	c.records = nil
+	c.mu.Unlock()
+}
+
+// Assert checks that the records captured so far are equal to want, as
+// DeepEqual decides with opts, and reports whether they are. If they
+// aren't, t fails with their differences.
+func (c *LogCapture) Assert(t testing.TB, want []LogRecord, opts ...Option) bool {
+	t.Helper()
+	got := c.Records()
+	if eq, _ := DeepEqual(want, got, opts...); eq {
+		return true
+	}
+	fail(t, opts, fmt.Sprintf("captured log records differ from want:\n%s%s", describeDiff(want, got, opts), artifactsNote(t, opts, func() []artifact {
+		return valueArtifacts(want, got, opts)
+	})))
+	return false
+}
+
+// captureHandler is the slog.Handler of a LogCapture. Its steps hold the
+// groups opened and attributes added by WithGroup and WithAttrs, in order.
+type captureHandler struct {
+	c     *LogCapture
+	steps []captureStep
+}
+
+// A captureStep is either a group or a list of attributes.
+type captureStep struct {
+	group string
+	attrs []slog.Attr
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *captureHandler) Handle(_ context.Context, r slog.Record) error {
+	rec := LogRecord{Level: r.Level, Message: r.Message, Attrs: make(map[string]interface{})}
+	var groups []string
+	for _, s := range h.steps {
+		if s.group == "" {
+			addGroupAttrs(rec.Attrs, groups, s.attrs)
+			continue
+		}
+		groups = append(groups, s.group)
+	}
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	addGroupAttrs(rec.Attrs, groups, attrs)
+	if len(rec.Attrs) == 0 {
+		rec.Attrs = nil
+	}
+	h.c.mu.Lock()
+	h.c.records = append(h.c.records, rec)
+	h.c.mu.Unlock()
+	return nil
+}
+
+func (h *captureHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(captureStep{attrs: attrs})
+}
+
+func (h *captureHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(captureStep{group: name})
+}
+
+func (h *captureHandler) with(s captureStep) *captureHandler {
+	steps := append(h.steps[:len(h.steps):len(h.steps)], s)
+	return &captureHandler{c: h.c, steps: steps}
+}
+
+// addGroupAttrs adds attrs to m within the groups opened by WithGroup, in
+// order, creating them only once an attribute lands in them, since slog
+// drops empty groups.
+func addGroupAttrs(m map[string]interface{}, groups []string, attrs []slog.Attr) {
+	g := make(map[string]interface{})
+	addAttrs(g, attrs)
+	if len(g) == 0 {
+		return
+	}
+	for _, key := range groups {
+		sub, ok := m[key].(map[string]interface{})
+		if !ok {
+			sub = make(map[string]interface{})
+			m[key] = sub
+		}
+		m = sub
+	}
+	for k, v := range g {
+		m[k] = v
+	}
+}
+
+// addAttrs adds attrs to m, following the rules of slog handlers: values
+// are resolved, empty attributes are dropped, and groups without a key are
+// inlined.
+func addAttrs(m map[string]interface{}, attrs []slog.Attr) {
+	for _, a := range attrs {
+		v := a.Value.Resolve()
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+		if v.Kind() != slog.KindGroup {
+			m[a.Key] = v.Any()
+			continue
+		}
+		if len(v.Group()) == 0 {
+			continue
+		}
+		if a.Key == "" {
+			addAttrs(m, v.Group())
+			continue
+		}
+		g := make(map[string]interface{})
+		addAttrs(g, v.Group())
+		if len(g) > 0 {
+			m[a.Key] = g
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package debugtools
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// A MapDiff is the result of DiffMaps.
+type MapDiff[K comparable] struct {
+	// OnlyLeft and OnlyRight hold the keys present in only one of the maps.
+	OnlyLeft, OnlyRight []K
+	// Changed holds the keys present in both maps whose values differ.
+	Changed []MapChange[K]
+}
+
+// A MapChange describes how the values for a key differ.
+type MapChange[K comparable] struct {
+	Key K
+	// Diff holds the differences between the two values, with paths
+	// relative to the values.
+	Diff *DiffTree
+}
+
+// Equal reports whether the maps had no differences.
+func (d *MapDiff[K]) Equal() bool {
+	return len(d.OnlyLeft) == 0 && len(d.OnlyRight) == 0 && len(d.Changed) == 0
+}
+
+// String lists the keys in each bucket and the differences for each
+// changed key.
+func (d *MapDiff[K]) String() string {
+	buf := &bytes.Buffer{}
+	for _, k := range d.OnlyLeft {
+		fmt.Fprintf(buf, "- %#v\n", k)
+	}
+	for _, k := range d.OnlyRight {
+		fmt.Fprintf(buf, "+ %#v\n", k)
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(buf, "~ %#v:\n", c.Key)
+		for _, n := range c.Diff.Leaves() {
+			fmt.Fprintf(buf, "    %s: %s\n", displayPath(n.Path), n.Message)
+		}
+	}
+	return buf.String()
+}
+
+// DiffMaps compares m1 and m2 key by key, unlike DeepEqual which stops at
+// the first problem. It returns the keys present only on each side and,
+// for the keys present in both, the differences between their values, as
+// found by Diff with opts. Keys in each bucket are sorted by their %#v
+// formatting.
+func DiffMaps[K comparable, V any](m1, m2 map[K]V, opts ...Option) *MapDiff[K] {
+	d := &MapDiff[K]{}
+	for k, v1 := range m1 {
+		v2, ok := m2[k]
+		if !ok {
+			d.OnlyLeft = append(d.OnlyLeft, k)
+			continue
+		}
+		// Both values have type V, so this can't fail.
+		tree, _ := DiffValues(reflect.ValueOf(&v1).Elem(), reflect.ValueOf(&v2).Elem(), opts...)
+		if !tree.Equal() {
+			d.Changed = append(d.Changed, MapChange[K]{Key: k, Diff: tree})
+		}
+	}
+	for k := range m2 {
+		if _, ok := m1[k]; !ok {
+			d.OnlyRight = append(d.OnlyRight, k)
+		}
+	}
+	sortKeys(d.OnlyLeft)
+	sortKeys(d.OnlyRight)
+	sort.Slice(d.Changed, func(i, j int) bool {
+		return fmt.Sprintf("%#v", d.Changed[i].Key) < fmt.Sprintf("%#v", d.Changed[j].Key)
+	})
+	return d
+}
+
+func sortKeys[K comparable](keys []K) {
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprintf("%#v", keys[i]) < fmt.Sprintf("%#v", keys[j])
+	})
+}
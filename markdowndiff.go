@@ -0,0 +1,97 @@
+package debugtools
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/pib/go-debugtools/textdiff"
+)
+
+// MarkdownFormatter renders a DiffTree as GitHub-flavored Markdown, for
+// posting into pull request comments: a table of the changes with their
+// old and new values, followed by a fenced unified diff for each multi-line
+// string that changed.
+type MarkdownFormatter struct{}
+
+func (MarkdownFormatter) FormatDiff(w io.Writer, d *DiffTree) error {
+	bw := bufio.NewWriter(w)
+	if d.Root == nil {
+		bw.WriteString("No differences.\n")
+		return bw.Flush()
+	}
+	var texts []*Difference
+	bw.WriteString("| | Path | Old | New |\n|---|---|---|---|\n")
+	for _, n := range d.Leaves() {
+		if _, _, ok := multilineStrings(n); ok {
+			texts = append(texts, n)
+			bw.WriteString("| ~ | " + mdCode(displayPath(n.Path)) + " | " + mdCell(n.Message) + " | see below |\n")
+			continue
+		}
+		mark := "~"
+		switch n.Kind {
+		case Inserted:
+			mark = "+"
+		case Deleted:
+			mark = "-"
+		}
+		bw.WriteString("| " + mark + " | " + mdCode(displayPath(n.Path)) + " | " + mdValue(n.LeftText) + " | " + mdValue(n.RightText) + " |\n")
+	}
+	for _, n := range texts {
+		s1, s2, _ := multilineStrings(n)
+		udiff := textdiff.Unified(s1, s2)
+		fence := strings.Repeat("`", max(3, longestRun(udiff, '`')+1))
+		bw.WriteString("\n" + mdCode(displayPath(n.Path)) + ":\n\n" + fence + "diff\n" + udiff + fence + "\n")
+	}
+	return bw.Flush()
+}
+
+// multilineStrings returns n's values if they are strings, at least one of
+// which has several lines.
+func multilineStrings(n *Difference) (string, string, bool) {
+	s1, ok1 := n.Left.(string)
+	s2, ok2 := n.Right.(string)
+	if !ok1 || !ok2 || !strings.Contains(s1, "\n") && !strings.Contains(s2, "\n") {
+		return "", "", false
+	}
+	return s1, s2, true
+}
+
+// mdValue formats a value for a table cell, which is empty if the value
+// doesn't exist on that side.
+func mdValue(text string) string {
+	if text == "" {
+		return ""
+	}
+	return mdCode(text)
+}
+
+// mdCode formats s as a code span within a table cell, using a backtick
+// fence longer than any run of backticks in s.
+func mdCode(s string) string {
+	s = strings.ReplaceAll(strings.ReplaceAll(s, "\n", " "), "|", `\|`)
+	fence := strings.Repeat("`", longestRun(s, '`')+1)
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
+
+// mdCell escapes s for use as text within a table cell.
+func mdCell(s string) string {
+	r := strings.NewReplacer("\n", " ", "|", `\|`, "\\", `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "<", "&lt;")
+	return r.Replace(s)
+}
+
+func longestRun(s string, c byte) int {
+	longest, n := 0, 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != c {
+			n = 0
+			continue
+		}
+		n++
+		longest = max(longest, n)
+	}
+	return longest
+}
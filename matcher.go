@@ -0,0 +1,47 @@
+package debugtools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Matcher matches values equal to an expected one, as DeepEqual decides
+// with a set of Options. It satisfies gomock's Matcher interface, and
+// others of the same shape, so that mock expectations can use tolerances,
+// ignored paths and the other options:
+//
+//	store.EXPECT().Save(debugtools.NewMatcher(wantOrder, debugtools.IgnorePaths("CreatedAt")))
+//
+// It also satisfies gomock's GotFormatter, so that an argument that
+// doesn't match is reported with its differences from the expected value.
+type Matcher struct {
+	want interface{}
+	opts []Option
+}
+
+// NewMatcher returns a Matcher for values equal to want.
+func NewMatcher(want interface{}, opts ...Option) *Matcher {
+	return &Matcher{want: want, opts: opts}
+}
+
+// Matcher returns a Matcher for values equal to want, with c's Options.
+func (c *Config) Matcher(want interface{}) *Matcher {
+	return NewMatcher(want, c.opts...)
+}
+
+// Matches reports whether x is equal to the expected value.
+func (m *Matcher) Matches(x interface{}) bool {
+	eq, _ := DeepEqual(m.want, x, m.opts...)
+	return eq
+}
+
+// String describes the values matched.
+func (m *Matcher) String() string {
+	return fmt.Sprintf("is deeply equal to %s", Sdump(m.want, DumpCompact()))
+}
+
+// Got describes x, which didn't match, by its differences from the
+// expected value.
+func (m *Matcher) Got(x interface{}) string {
+	return fmt.Sprintf("%s, which differs:\n%s", Sdump(x, DumpCompact()), strings.TrimSuffix(describeDiff(m.want, x, m.opts), "\n"))
+}
@@ -0,0 +1,94 @@
+package debugtools
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+)
+
+type numClass int
+
+const (
+	notNumeric numClass = iota
+	signedNum
+	unsignedNum
+	floatNum
+)
+
+func numericClass(k reflect.Kind) numClass {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return signedNum
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return unsignedNum
+	case reflect.Float32, reflect.Float64:
+		return floatNum
+	}
+	return notNumeric
+}
+
+// numericEqual compares two numbers of different types by value. A value
+// that cannot be represented exactly in the other's class, such as a
+// fractional float against an int or a uint64 above math.MaxInt64 against
+// an int64, makes them unequal, and the overflow is noted in the trace.
+func (s *deepEqualState) numericEqual(v1, v2 reflect.Value) bool {
+	a, ca, b, cb := v1, numericClass(v1.Kind()), v2, numericClass(v2.Kind())
+	if ca > cb {
+		a, ca, b, cb = b, cb, a, ca
+	}
+	eq, note := numbersEqual(a, ca, b, cb)
+	op := "!="
+	if eq {
+		op = "=="
+	}
+	if note != "" {
+		s.printf("%v (%s) != %v (%s): %v %s\n", numString(v1), v1.Type(), numString(v2), v2.Type(), numString(b), note)
+		return s.report(false, "%v (%s) != %v (%s): %v %s", numString(v1), v1.Type(), numString(v2), v2.Type(), numString(b), note)
+	}
+	s.printf("%v (%s) %s %v (%s)\n", numString(v1), v1.Type(), op, numString(v2), v2.Type())
+	return s.report(eq, "%v (%s) %s %v (%s)", numString(v1), v1.Type(), op, numString(v2), v2.Type())
+}
+
+// numbersEqual compares a and b where ca <= cb. If b cannot be represented
+// in a's class, it returns a note saying why.
+func numbersEqual(a reflect.Value, ca numClass, b reflect.Value, cb numClass) (bool, string) {
+	switch {
+	case ca == signedNum && cb == signedNum:
+		return a.Int() == b.Int(), ""
+	case ca == signedNum && cb == unsignedNum:
+		if b.Uint() > math.MaxInt64 {
+			return false, "overflows int64"
+		}
+		return a.Int() == int64(b.Uint()), ""
+	case ca == unsignedNum && cb == unsignedNum:
+		return a.Uint() == b.Uint(), ""
+	case cb == floatNum && ca == floatNum:
+		return a.Float() == b.Float(), ""
+	}
+	// An integer against a float.
+	f := b.Float()
+	if f != math.Trunc(f) {
+		return false, "is not an integer"
+	}
+	if ca == signedNum {
+		if f < -(1<<63) || f >= 1<<63 {
+			return false, "overflows int64"
+		}
+		return a.Int() == int64(f), ""
+	}
+	if f < 0 || f >= 1<<64 {
+		return false, "overflows uint64"
+	}
+	return a.Uint() == uint64(f), ""
+}
+
+// numString formats a number in decimal, whatever its kind.
+func numString(v reflect.Value) string {
+	switch numericClass(v.Kind()) {
+	case signedNum:
+		return strconv.FormatInt(v.Int(), 10)
+	case unsignedNum:
+		return strconv.FormatUint(v.Uint(), 10)
+	}
+	return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+}
@@ -0,0 +1,280 @@
+package debugtools
+
+import (
+	"reflect"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/pib/go-debugtools/textdiff"
+)
+
+// An Option configures how values are compared and how the trace is
+// produced. Options are passed to DeepEqual and DeepValueEqual.
+type Option func(*options)
+
+// options holds the settings accumulated from a list of Options.
+type options struct {
+	reporter Reporter
+	level    TraceLevel
+	maxTrace int
+	ignore   []string
+	only     []string
+	mapKeys  map[reflect.Type]func(interface{}) interface{}
+
+	durationTolerance time.Duration
+	normalizer        Normalizer
+	equateNumeric     bool
+	chanPolicy        ChanPolicy
+	textContext       int
+	wordDiff          *textdiff.Highlighter
+	mismatchTemplate  *template.Template
+	collapseReplaced  bool
+	rawTimes          bool
+
+	// Settings for Dump.
+	dumpGoSyntax   bool
+	dumpCompact    bool
+	dumpIndent     *string
+	dumpWidth      int
+	dumpInline     bool
+	dumpColor      ColorMode
+	dumpAddresses  bool
+	dumpUnexported bool
+	dumpNoMethods  bool
+	dumpMaxDepth   int
+	dumpTruncate   int
+	dumpTags       bool
+	dumpRedact     []string
+
+	// Settings for DiffBenchmarks.
+	benchThresholds map[string]float64
+
+	// Settings for DiffTestEvents.
+	testThreshold *durationThreshold
+
+	// Settings for the assertion helpers.
+	failureArtifacts bool
+	pageFailures     bool
+
+	// observe, if set, is called with the values about to be compared at
+	// each step, so that Diff can record them for collapsed subtrees.
+	observe func(v1, v2 reflect.Value)
+
+	// plans, if set, caches the typePlan of each type compared with the
+	// options, for options kept for reuse.
+	plans *sync.Map // reflect.Type -> *typePlan
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		textContext: textdiff.DefaultContext,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// TraceLevel controls how much of the comparison is written to the trace.
+type TraceLevel int
+
+const (
+	// TraceErrors writes only the mismatches, each prefixed by its path.
+	TraceErrors TraceLevel = -1
+	// TraceNormal writes every comparison made, if the values differ. This
+	// is the default.
+	TraceNormal TraceLevel = 0
+	// TraceVerbose is like TraceNormal, but also descends into values that
+	// are known to be equal because they share an address or backing
+	// array, so that every leaf equality appears in the trace, and writes
+	// the trace even when the values are equal.
+	TraceVerbose TraceLevel = 1
+)
+
+// WithTraceLevel sets how much of the comparison is written to the trace.
+func WithTraceLevel(l TraceLevel) Option {
+	return func(o *options) {
+		o.level = l
+	}
+}
+
+// WithMaxTraceBytes stops the trace from growing once it reaches about n
+// bytes. Anything after that is dropped, and a note saying how many more
+// events there were is appended. Zero means no limit.
+func WithMaxTraceBytes(n int) Option {
+	return func(o *options) {
+		o.maxTrace = n
+	}
+}
+
+// IgnorePaths skips the subtrees at the given paths, such as "User.Password"
+// or "Items[0]". Paths are written as in Result.Path; the leading "." may be
+// left off. Dump leaves the subtrees out.
+func IgnorePaths(paths ...string) Option {
+	return func(o *options) {
+		o.ignore = append(o.ignore, normalizePaths(paths)...)
+	}
+}
+
+// OnlyPaths restricts the comparison to the subtrees at the given paths,
+// such as "User.Name" and "User.Email". Everything outside them is skipped,
+// which keeps the trace focused on the fields of interest. Dump likewise
+// shows only those subtrees, and the values enclosing them.
+func OnlyPaths(paths ...string) Option {
+	return func(o *options) {
+		o.only = append(o.only, normalizePaths(paths)...)
+	}
+}
+
+// excluded reports whether the subtree at path is skipped by IgnorePaths or
+// OnlyPaths.
+func (o *options) excluded(path string) bool {
+	for _, p := range o.ignore {
+		if pathWithin(path, p) {
+			return true
+		}
+	}
+	if len(o.only) == 0 {
+		return false
+	}
+	for _, p := range o.only {
+		if pathWithin(path, p) || pathWithin(p, path) {
+			return false
+		}
+	}
+	return true
+}
+
+// CanonicalMapKeys matches up the keys of maps of type mapType by the value
+// canon returns for them rather than by ==, so that keys differing only
+// cosmetically, such as header names in different cases, have their values
+// compared. canon must return comparable values.
+func CanonicalMapKeys(mapType reflect.Type, canon func(key interface{}) interface{}) Option {
+	if mapType.Kind() != reflect.Map {
+		panic("debugtools: CanonicalMapKeys called with non-map type " + mapType.String())
+	}
+	return func(o *options) {
+		if o.mapKeys == nil {
+			o.mapKeys = make(map[reflect.Type]func(interface{}) interface{})
+		}
+		o.mapKeys[mapType] = canon
+	}
+}
+
+// WithDurationTolerance treats time.Duration values as equal if they differ
+// by no more than d, for measured latencies or TTLs that jitter between runs.
+func WithDurationTolerance(d time.Duration) Option {
+	return func(o *options) {
+		o.durationTolerance = d
+	}
+}
+
+// A Normalizer maps a string to its normal form. The forms in
+// golang.org/x/text/unicode/norm, such as norm.NFC, are Normalizers.
+type Normalizer interface {
+	String(s string) string
+}
+
+// NormalizeUnicode treats strings as equal if they are the same after
+// normalization by n, for example NormalizeUnicode(norm.NFC). The trace
+// notes when normalization was the only difference.
+func NormalizeUnicode(n Normalizer) Option {
+	return func(o *options) {
+		o.normalizer = n
+	}
+}
+
+// EquateNumericKinds compares integers and floats of different types by
+// value, so that an int in one value matches a float64 in the other, as
+// happens when comparing decoded YAML or JSON with hand-built literals.
+// Values that can't be represented exactly in the other's type, such as
+// 1.5 against an int, are unequal.
+func EquateNumericKinds() Option {
+	return func(o *options) {
+		o.equateNumeric = true
+	}
+}
+
+// ChanPolicy says how channels are compared.
+type ChanPolicy int
+
+const (
+	// ChanIdentity treats channels as equal if they are the same channel,
+	// or both nil. This is the default.
+	ChanIdentity ChanPolicy = iota
+	// ChanNilness treats channels as equal if both are nil or both are
+	// non-nil.
+	ChanNilness
+	// ChanIgnore treats all channels as equal.
+	ChanIgnore
+)
+
+func (p ChanPolicy) String() string {
+	switch p {
+	case ChanIdentity:
+		return "ChanIdentity"
+	case ChanNilness:
+		return "ChanNilness"
+	case ChanIgnore:
+		return "ChanIgnore"
+	}
+	return "ChanPolicy(" + strconv.Itoa(int(p)) + ")"
+}
+
+// WithChanPolicy sets how channels are compared.
+func WithChanPolicy(p ChanPolicy) Option {
+	return func(o *options) {
+		o.chanPolicy = p
+	}
+}
+
+// WithTextContext sets how many unchanged lines are shown around each change
+// when the trace shows a line diff of two multi-line strings, as diff -U n
+// does. The default is textdiff.DefaultContext.
+func WithTextContext(n int) Option {
+	return func(o *options) {
+		o.textContext = n
+	}
+}
+
+// WithWordDiff shows changed lines of multi-line strings, and the changed
+// part of long single-line strings, with the differing words highlighted
+// by h, for example textdiff.Markers or textdiff.ANSI, instead of as whole
+// removed and added lines.
+func WithWordDiff(h textdiff.Highlighter) Option {
+	return func(o *options) {
+		o.wordDiff = &h
+	}
+}
+
+// WithMismatchTemplate formats the mismatch lines written at TraceErrors
+// by executing t with a MismatchLine, instead of as "path: message". A
+// newline is added after each line if t doesn't end with one. For example:
+//
+//	template.Must(template.New("").Parse(`{{.Kind}} {{.Path}} left={{.Left}} right={{.Right}}`))
+func WithMismatchTemplate(t *template.Template) Option {
+	return func(o *options) {
+		o.mismatchTemplate = t
+	}
+}
+
+// CollapseReplaced makes Diff report a struct, slice, array or map in which
+// every value differs as a single change, "Entire subtree replaced", rather
+// than as a change for each of its values. It keeps the report short when a
+// whole embedded value has been swapped for another.
+func CollapseReplaced() Option {
+	return func(o *options) {
+		o.collapseReplaced = true
+	}
+}
+
+// RawTimes shows time.Time and time.Duration values in traces and dumps by
+// their internals, as for any other struct or integer, rather than as
+// RFC 3339 timestamps and durations like 1h23m0s.
+func RawTimes() Option {
+	return func(o *options) {
+		o.rawTimes = true
+	}
+}
@@ -0,0 +1,46 @@
+package debugtools
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// pagerMinLines is the length a failure message must exceed to be paged.
+const pagerMinLines = 40
+
+// PageFailures makes the assertion helpers show failure messages longer
+// than a screenful in a pager before reporting them, so that a large diff
+// can be scrolled and searched rather than scrolling off the terminal. The
+// pager is $PAGER, or less -R, which keeps colors, if PAGER is unset. It is
+// only used in verbose runs, with -v, whose standard output is a terminal,
+// and never when the CI environment variable is set, so the option can be
+// left on in code shared with CI. The message is reported as usual once
+// the pager exits.
+func PageFailures() Option {
+	return func(o *options) {
+		o.pageFailures = true
+	}
+}
+
+// page shows msg in the pager, if it is long and the test output is being
+// read on a terminal. Errors running the pager are ignored, since the
+// message is reported anyway.
+func page(msg string) {
+	if strings.Count(msg, "\n") < pagerMinLines || !testing.Verbose() || !isTerminal(os.Stdout) {
+		return
+	}
+	if _, ok := os.LookupEnv("CI"); ok {
+		return
+	}
+	args := strings.Fields(os.Getenv("PAGER"))
+	if len(args) == 0 {
+		args = []string{"less", "-R"}
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(msg + "\n")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Run()
+}
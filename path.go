@@ -0,0 +1,33 @@
+package debugtools
+
+import "strings"
+
+// normalizePath turns a user-supplied path such as "User.Name" into the
+// form used during traversal, ".User.Name".
+func normalizePath(p string) string {
+	if p == "" || p[0] == '.' || p[0] == '[' {
+		return p
+	}
+	return "." + p
+}
+
+func normalizePaths(ps []string) []string {
+	out := make([]string, len(ps))
+	for i, p := range ps {
+		out[i] = normalizePath(p)
+	}
+	return out
+}
+
+// pathWithin reports whether path is prefix itself or lies in the subtree
+// below it.
+func pathWithin(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	if len(path) == len(prefix) || prefix == "" {
+		return true
+	}
+	c := path[len(prefix)]
+	return c == '.' || c == '['
+}
@@ -0,0 +1,108 @@
+package debugtools
+
+import (
+	"reflect"
+	"sync"
+)
+
+// A typePlan is what comparing values of one type needs to know about it
+// under a set of options, worked out once for the type rather than at
+// every value: its fields, and which of the options apply to it.
+type typePlan struct {
+	*typeInfo
+	// special is set if specialEqual may compare values of the type
+	// rather than the usual comparison for their kind.
+	special bool
+	// canon is the function from CanonicalMapKeys for a map type, if any.
+	canon func(interface{}) interface{}
+	// flat is set if values of a struct type can be compared whole, as
+	// flatEqual does, when nothing is listening for their fields.
+	flat bool
+}
+
+// A typeInfo is the part of a typePlan that doesn't depend on the options,
+// shared by every comparison.
+type typeInfo struct {
+	// hard is set for the kinds whose comparisons are recorded as
+	// visited, to short circuit cycles.
+	hard bool
+	// fields lists the fields of a struct type.
+	fields []fieldPlan
+	// special is set if specialEqual may compare values of the type under
+	// some options.
+	special bool
+	// flat and raw are set for a struct type as flatType reports.
+	flat, raw bool
+	// valuer is set if values of the type are compared by their
+	// driver.Value, as sql.NullString and friends are. Pointers and
+	// interfaces are followed to the value they hold first.
+	valuer bool
+}
+
+// A fieldPlan describes a struct field for the comparison.
+type fieldPlan struct {
+	name string
+	// step is the path step to the field, "." and its name.
+	step string
+	// asJSON is set if the field is tagged `deepequal:"json"`.
+	asJSON bool
+}
+
+// typeInfos caches the typeInfo of each type compared so far.
+var typeInfos sync.Map // reflect.Type -> *typeInfo
+
+// infoFor returns the typeInfo of t.
+func infoFor(t reflect.Type) *typeInfo {
+	if info, ok := typeInfos.Load(t); ok {
+		return info.(*typeInfo)
+	}
+	info := &typeInfo{}
+	switch t.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.Struct:
+		info.hard = true
+	}
+	if t.Kind() == reflect.Struct {
+		info.fields = make([]fieldPlan, t.NumField())
+		for i := range info.fields {
+			f := t.Field(i)
+			info.fields[i] = fieldPlan{
+				name:   f.Name,
+				step:   "." + f.Name,
+				asJSON: hasTagFlag(f, tagKey, "json"),
+			}
+		}
+		info.flat, info.raw = flatType(t)
+	}
+	info.valuer = t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface && t.Implements(valuerType)
+	info.special = t == durationType || t == timeType || t == rawMessageType ||
+		t.Kind() == reflect.String || isBytes(t) || isBig(t) || info.valuer
+	actual, _ := typeInfos.LoadOrStore(t, info)
+	return actual.(*typeInfo)
+}
+
+// planFor returns the plan for comparing values of type t with o. The plans
+// of options kept for reuse, by a Comparer or a Config, are cached with
+// them; others are worked out as needed, from the cached typeInfo.
+func (o *options) planFor(t reflect.Type) typePlan {
+	if o.plans != nil {
+		if p, ok := o.plans.Load(t); ok {
+			return *p.(*typePlan)
+		}
+	}
+	info := infoFor(t)
+	p := typePlan{typeInfo: info, special: info.special}
+	if o.rawTimes && (t == timeType || t == durationType && o.durationTolerance == 0) {
+		p.special = false
+	}
+	if t.Kind() == reflect.Map {
+		p.canon = o.mapKeys[t]
+	}
+	// Options that change how leaves compare, or skip some of them, need
+	// the fields walked.
+	p.flat = info.flat && o.durationTolerance == 0 && o.normalizer == nil && len(o.ignore) == 0 && len(o.only) == 0
+	if o.plans != nil {
+		cached := p
+		o.plans.Store(t, &cached)
+	}
+	return p
+}
@@ -0,0 +1,66 @@
+package debugtools
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// propertySamples is the number of values CheckEqualityProperties
+// generates.
+const propertySamples = 100
+
+// CheckEqualityProperties checks that DeepEqual, with opts, is reflexive and
+// symmetric over values made by gen: that every value equals itself, and
+// that comparing two values gives the same answer both ways round. Custom
+// comparers, transformers and normalizers, and unusual values such as NaN,
+// can break these properties, which makes assertions built on them
+// unreliable. gen is called a hundred times, and should return values of
+// the kind the option set will be used with, drawn at random from a small
+// enough space that some of them are equal, so that symmetry is checked
+// for equal values as well as unequal ones. Every pair of values is
+// compared both ways round.
+//
+// t fails with the first counterexample to each property, dumped, along
+// with the trace of the comparison that went wrong.
+func CheckEqualityProperties(t testing.TB, gen func() interface{}, opts ...Option) {
+	t.Helper()
+	values := make([]interface{}, propertySamples)
+	for i := range values {
+		values[i] = gen()
+	}
+	var reflexive, symmetric int
+	var reflexiveExample, symmetricExample string
+	for i, v := range values {
+		if eq, trace := DeepEqual(v, v, opts...); !eq {
+			if reflexive == 0 {
+				reflexiveExample = fmt.Sprintf("value = %s\ntrace:\n%s", propertyDump(v), trace)
+			}
+			reflexive++
+		}
+		for _, w := range values[i+1:] {
+			eq1, trace1 := DeepEqual(v, w, opts...)
+			eq2, trace2 := DeepEqual(w, v, opts...)
+			if eq1 != eq2 {
+				if symmetric == 0 {
+					symmetricExample = fmt.Sprintf("a = %s\nb = %s\nDeepEqual(a, b) = %t, trace:\n%s\nDeepEqual(b, a) = %t, trace:\n%s",
+						propertyDump(v), propertyDump(w), eq1, trace1, eq2, trace2)
+				}
+				symmetric++
+			}
+		}
+	}
+	pairs := len(values) * (len(values) - 1) / 2
+	if reflexive > 0 {
+		t.Errorf("DeepEqual is not reflexive: %d of %d values don't equal themselves; for example:\n%s",
+			reflexive, len(values), reflexiveExample)
+	}
+	if symmetric > 0 {
+		t.Errorf("DeepEqual is not symmetric: %d of %d pairs compare differently each way round; for example:\n%s",
+			symmetric, pairs, symmetricExample)
+	}
+}
+
+func propertyDump(v interface{}) string {
+	return strings.TrimSuffix(Sdump(v, DumpColor(ColorNever), DumpUnexported()), "\n")
+}
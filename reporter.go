@@ -0,0 +1,70 @@
+package debugtools
+
+import "reflect"
+
+// A Reporter receives events as a comparison traverses two values, so that
+// callers can produce their own output (metrics, structured logs, UIs)
+// instead of, or as well as, the text trace.
+//
+// Paths are written relative to the values being compared: the roots have
+// the empty path, struct fields are written as ".Field", and array, slice
+// and map elements as "[index]" or "[key]". Pointers and interfaces are
+// followed without adding to the path.
+type Reporter interface {
+	// PushStep is called when the comparison descends into path.
+	PushStep(path string)
+	// Report is called with the outcome of comparing the values at the
+	// path most recently pushed.
+	Report(r Result)
+	// PopStep is called when the comparison returns from the path most
+	// recently pushed.
+	PopStep()
+}
+
+// Result describes the outcome of comparing the values at a single path.
+// Left or Right is the zero Value if the path only exists on the other side.
+type Result struct {
+	Path        string
+	Equal       bool
+	Message     string
+	Left, Right reflect.Value
+}
+
+// WithReporter sends traversal events to r.
+func WithReporter(r Reporter) Option {
+	return func(o *options) {
+		o.reporter = r
+	}
+}
+
+// teeReporter returns a Reporter that passes events to both r1 and r2,
+// either of which may be nil.
+func teeReporter(r1, r2 Reporter) Reporter {
+	switch {
+	case r1 == nil:
+		return r2
+	case r2 == nil:
+		return r1
+	}
+	return multiReporter{r1, r2}
+}
+
+type multiReporter []Reporter
+
+func (m multiReporter) PushStep(path string) {
+	for _, r := range m {
+		r.PushStep(path)
+	}
+}
+
+func (m multiReporter) Report(res Result) {
+	for _, r := range m {
+		r.Report(res)
+	}
+}
+
+func (m multiReporter) PopStep() {
+	for _, r := range m {
+		r.PopStep()
+	}
+}
@@ -0,0 +1,78 @@
+package debugtools
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SideBySideFormatter renders a DiffTree in columns for a terminal: each
+// change on its own row, with its path, the left value and the right value
+// aligned under each other. Values too wide for their column are truncated.
+type SideBySideFormatter struct {
+	// Width is the total width of the output. If zero it is taken from the
+	// COLUMNS environment variable, or 80 if that isn't set.
+	Width int
+}
+
+func (f SideBySideFormatter) FormatDiff(w io.Writer, d *DiffTree) error {
+	width := f.Width
+	if width <= 0 {
+		width = terminalWidth()
+	}
+	leaves := d.Leaves()
+	pathWidth := len("path")
+	for _, n := range leaves {
+		pathWidth = max(pathWidth, utf8.RuneCountInString(displayPath(n.Path)))
+	}
+	// Each row is "~ path │ left │ right".
+	const sepWidth = 2 + 3 + 3
+	pathWidth = min(pathWidth, (width-sepWidth)/3)
+	valueWidth := max((width-sepWidth-pathWidth)/2, 1)
+
+	bw := bufio.NewWriter(w)
+	writeRow := func(mark, path, left, right string) {
+		row := mark + " " + fitColumn(path, pathWidth) + " │ " + fitColumn(left, valueWidth) + " │ " + fitColumn(right, valueWidth)
+		bw.WriteString(strings.TrimRight(row, " ") + "\n")
+	}
+	writeRow(" ", "path", "left", "right")
+	bw.WriteString(strings.Repeat("─", pathWidth+3) + "┼" + strings.Repeat("─", valueWidth+2) + "┼" + strings.Repeat("─", valueWidth+1) + "\n")
+	for _, n := range leaves {
+		mark := "~"
+		switch n.Kind {
+		case Inserted:
+			mark = "+"
+		case Deleted:
+			mark = "-"
+		}
+		writeRow(mark, displayPath(n.Path), n.LeftText, n.RightText)
+	}
+	return bw.Flush()
+}
+
+// terminalWidth returns the width of the terminal as given by $COLUMNS, or
+// 80.
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 80
+}
+
+// fitColumn pads or truncates s to exactly width runes, replacing any
+// newlines with spaces.
+func fitColumn(s string, width int) string {
+	s = strings.ReplaceAll(s, "\n", " ")
+	n := utf8.RuneCountInString(s)
+	if n <= width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	if width <= 1 {
+		return strings.Repeat("…", width)
+	}
+	r := []rune(s)
+	return string(r[:width-1]) + "…"
+}
@@ -0,0 +1,285 @@
+package debugtools
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// DeepSize returns an estimate of the number of bytes retained by v: its
+// own size and that of everything reachable from it through pointers,
+// slices, strings, maps, channels and interfaces, including unexported
+// fields. Memory reachable along several paths is counted once, and
+// cycles are followed only once. The sizes of maps and channels are
+// estimated from their lengths and capacities, and allocator overhead is
+// not counted, so the result is approximate.
+func DeepSize(v interface{}) uint64 {
+	s := &sizeState{}
+	s.root(v)
+	return s.total
+}
+
+// A PathSize is the number of bytes retained under a path.
+type PathSize struct {
+	Path  string
+	Bytes uint64
+}
+
+// DeepSizeByPath breaks down DeepSize(v) by path, to at most depth steps,
+// biggest first, so that the parts of a value holding most of its memory
+// can be found. The size under each path includes the sizes under the
+// paths below it, as with du, and memory reachable along several paths is
+// counted under the first one reached. The first entry is for the whole
+// value, with path "".
+func DeepSizeByPath(v interface{}, depth int) []PathSize {
+	s := &sizeState{depth: depth, sizes: make(map[string]uint64)}
+	s.root(v)
+	sizes := []PathSize{{Path: "", Bytes: s.total}}
+	for p, n := range s.sizes {
+		sizes = append(sizes, PathSize{Path: p, Bytes: n})
+	}
+	sort.SliceStable(sizes[1:], func(i, j int) bool {
+		a, b := sizes[1+i], sizes[1+j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Path < b.Path
+	})
+	return sizes
+}
+
+// sizeState holds the progress of DeepSize.
+type sizeState struct {
+	// seen holds the memory counted so far, as extents rather than start
+	// addresses, since a pointer into a struct or a shorter slice of an
+	// array may be reached before the whole.
+	seen  extents
+	total uint64
+	// depth and sizes are set for DeepSizeByPath, which counts the bytes
+	// under each path of up to depth steps in sizes.
+	depth int
+	sizes map[string]uint64
+	path  []string
+}
+
+func (s *sizeState) root(v interface{}) {
+	if v == nil {
+		return
+	}
+	rv := reflect.ValueOf(v)
+	s.inline(rv)
+	s.indirect(rv)
+}
+
+// add counts n bytes under the current path.
+func (s *sizeState) add(n uintptr) {
+	s.total += uint64(n)
+	if s.sizes == nil {
+		return
+	}
+	var p strings.Builder
+	for i, step := range s.path {
+		if i >= s.depth {
+			break
+		}
+		p.WriteString(step)
+		s.sizes[p.String()] += uint64(n)
+	}
+}
+
+// pushStep adds a step to the path, calling step for it only if sizes are
+// being counted at that depth.
+func (s *sizeState) pushStep(step func() string) {
+	if s.sizes != nil && len(s.path) < s.depth {
+		s.path = append(s.path, step())
+	} else {
+		s.path = append(s.path, "")
+	}
+}
+
+func (s *sizeState) popStep() {
+	s.path = s.path[:len(s.path)-1]
+}
+
+// mark records the n bytes at addr as counted, and returns how many of
+// them weren't already.
+func (s *sizeState) mark(addr, n uintptr) uintptr {
+	if addr == 0 {
+		return 0
+	}
+	return s.seen.add(addr, addr+n)
+}
+
+// An extent is the memory from start up to end.
+type extent struct {
+	start, end uintptr
+}
+
+// extents is a set of memory, as disjoint extents in order of address.
+type extents []extent
+
+// add adds the memory from start up to end to e, and returns how many of
+// its bytes weren't already in e.
+func (e *extents) add(start, end uintptr) uintptr {
+	x := *e
+	// The extents overlapping or touching the new one are merged with it.
+	i := sort.Search(len(x), func(i int) bool { return x[i].end >= start })
+	j := i
+	merged := extent{start, end}
+	covered := uintptr(0)
+	for ; j < len(x) && x[j].start <= end; j++ {
+		covered += min(x[j].end, end) - max(x[j].start, start)
+		merged.start = min(merged.start, x[j].start)
+		merged.end = max(merged.end, x[j].end)
+	}
+	if i == j {
+		x = append(x, extent{})
+		copy(x[i+1:], x[i:])
+	} else {
+		x = append(x[:i+1], x[j:]...)
+	}
+	x[i] = merged
+	*e = x
+	return end - start - covered
+}
+
+// inline counts the memory v occupies itself, attributing that of struct
+// fields to their paths.
+func (s *sizeState) inline(v reflect.Value) {
+	t := v.Type()
+	if t.Kind() != reflect.Struct || s.sizes == nil {
+		s.add(t.Size())
+		return
+	}
+	var fields uintptr
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		s.pushStep(func() string { return "." + f.Name })
+		s.inline(v.Field(i))
+		s.popStep()
+		fields += f.Type.Size()
+	}
+	// Padding.
+	s.add(t.Size() - fields)
+}
+
+// indirect counts the memory v refers to.
+func (s *sizeState) indirect(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		e := v.Elem()
+		n := s.mark(v.Pointer(), e.Type().Size())
+		switch {
+		case n == 0:
+			return
+		case n == e.Type().Size():
+			s.inline(e)
+		default:
+			// Some of it was counted through a pointer into it.
+			s.add(n)
+		}
+		s.indirect(e)
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		e := v.Elem()
+		switch e.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+			// Stored in the interface itself.
+		default:
+			s.add(e.Type().Size())
+		}
+		s.indirect(e)
+	case reflect.String:
+		if v.Len() == 0 {
+			return
+		}
+		if n := s.mark(uintptr(unsafe.Pointer(unsafe.StringData(v.String()))), uintptr(v.Len())); n > 0 {
+			s.add(n)
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		if n := s.mark(v.Pointer(), uintptr(v.Cap())*v.Type().Elem().Size()); n > 0 {
+			s.add(n)
+		}
+		s.elements(v)
+	case reflect.Array:
+		s.elements(v)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			s.pushStep(func() string { return "." + f.Name })
+			s.indirect(v.Field(i))
+			s.popStep()
+		}
+	case reflect.Map:
+		// Only the header is marked, as nothing else points into a map.
+		if v.IsNil() || s.mark(v.Pointer(), 1) == 0 {
+			return
+		}
+		s.add(mapSize(v))
+		iter := v.MapRange()
+		for iter.Next() {
+			k := iter.Key()
+			s.pushStep(func() string { return "[" + anyString(k) + "]" })
+			s.indirect(k)
+			s.indirect(iter.Value())
+			s.popStep()
+		}
+	case reflect.Chan:
+		if v.IsNil() || s.mark(v.Pointer(), 1) == 0 {
+			return
+		}
+		// The channel header, and its buffer.
+		s.add(96 + uintptr(v.Cap())*v.Type().Elem().Size())
+	}
+}
+
+func (s *sizeState) elements(v reflect.Value) {
+	if !hasPointers(v.Type().Elem()) {
+		return
+	}
+	for i := 0; i < v.Len(); i++ {
+		s.pushStep(func() string { return "[" + strconv.Itoa(i) + "]" })
+		s.indirect(v.Index(i))
+		s.popStep()
+	}
+}
+
+// mapSize estimates the memory used by the map v itself: a header, and
+// groups of eight slots, each with a control byte, kept at most 7/8 full.
+func mapSize(v reflect.Value) uintptr {
+	t := v.Type()
+	slots := uintptr(8)
+	for slots*7/8 < uintptr(v.Len()) {
+		slots *= 2
+	}
+	return 48 + slots*(t.Key().Size()+t.Elem().Size()+1)
+}
+
+// hasPointers reports whether values of type t can refer to other memory.
+func hasPointers(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Array:
+		return t.Len() > 0 && hasPointers(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasPointers(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	case reflect.Ptr, reflect.Interface, reflect.String, reflect.Slice, reflect.Map,
+		reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return true
+	}
+	return false
+}
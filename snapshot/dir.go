@@ -0,0 +1,154 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/pib/go-debugtools/textdiff"
+)
+
+// MatchDir checks the files in got, such as the output directory of a code
+// generator, against the golden directory testdata/snapshots/<name>. If
+// they differ, the test fails with the files only in got, the files only in
+// the golden directory, and the differences within each file in both: a
+// line diff for text files, and a diff of their hex dumps for binary ones.
+// When snapshots are being updated, the golden directory is made to match
+// got instead, removing the files it no longer has, and when they are being
+// reviewed, got is copied to the directory beside it with the .received
+// suffix. Golden files are stored as they are, without a format version
+// line, and only regular files are compared.
+//
+//	snapshot.MatchDir(t, "petstore", os.DirFS(outDir))
+func MatchDir(t testing.TB, name string, got fs.FS) {
+	t.Helper()
+	golden := filepath.Join(dir, filepath.FromSlash(name))
+	gotFiles, err := readTree(got)
+	if err != nil {
+		t.Fatalf("snapshot: reading files to match: %v", err)
+	}
+	wantFiles, err := readTree(os.DirFS(golden))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("snapshot: %v", err)
+	}
+	d := compareTrees(wantFiles, gotFiles)
+	if d.empty() {
+		removeReceived(golden)
+		return
+	}
+	if Updating() {
+		for _, path := range append(d.added, d.changed...) {
+			if err := writeFile(filepath.Join(golden, filepath.FromSlash(path)), gotFiles[path]); err != nil {
+				t.Fatalf("snapshot: %v", err)
+			}
+		}
+		for _, path := range d.removed {
+			file := filepath.Join(golden, filepath.FromSlash(path))
+			if err := os.Remove(file); err != nil {
+				t.Fatalf("snapshot: %v", err)
+			}
+			recordUpdate(file)
+		}
+		t.Logf("snapshot: updated %s: %d added, %d removed, %d changed", golden, len(d.added), len(d.removed), len(d.changed))
+		return
+	}
+	if Reviewing() {
+		if err := writeReceivedTree(golden, gotFiles); err != nil {
+			t.Fatalf("snapshot: %v", err)
+		}
+		t.Errorf("snapshot: %s does not match; wrote %s%s for review:\n%s", golden, golden, receivedSuffix, d)
+		return
+	}
+	t.Errorf("snapshot: %s does not match; run the test with -update to rewrite it:\n%s", golden, d)
+}
+
+// A treeDiff holds the differences between two trees of files read by
+// readTree, by their paths, in order.
+type treeDiff struct {
+	want, got               map[string][]byte
+	added, removed, changed []string
+}
+
+// compareTrees compares the golden files want with the files got.
+func compareTrees(want, got map[string][]byte) *treeDiff {
+	d := &treeDiff{want: want, got: got}
+	for path, data := range got {
+		w, ok := want[path]
+		switch {
+		case !ok:
+			d.added = append(d.added, path)
+		case !bytes.Equal(w, data):
+			d.changed = append(d.changed, path)
+		}
+	}
+	for path := range want {
+		if _, ok := got[path]; !ok {
+			d.removed = append(d.removed, path)
+		}
+	}
+	sort.Strings(d.added)
+	sort.Strings(d.removed)
+	sort.Strings(d.changed)
+	return d
+}
+
+func (d *treeDiff) empty() bool {
+	return len(d.added)+len(d.removed)+len(d.changed) == 0
+}
+
+// String lists the files only in got, the files only in want, and the
+// differences within the files in both.
+func (d *treeDiff) String() string {
+	var lines []string
+	for _, path := range d.added {
+		lines = append(lines, fmt.Sprintf("+ %s: only in got", path))
+	}
+	for _, path := range d.removed {
+		lines = append(lines, fmt.Sprintf("- %s: only in snapshot", path))
+	}
+	for _, path := range d.changed {
+		lines = append(lines, fmt.Sprintf("~ %s (-snapshot +got):\n%s", path, strings.TrimSuffix(fileDiff(d.want[path], d.got[path]), "\n")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// readTree returns the contents of the regular files in fsys, by their
+// slash-separated paths.
+func readTree(fsys fs.FS) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		files[path] = data
+		return nil
+	})
+	return files, err
+}
+
+// fileDiff returns a line diff of want and got, or of their hex dumps if
+// either isn't text.
+func fileDiff(want, got []byte) string {
+	if isText(want) && isText(got) {
+		return textdiff.Unified(string(want), string(got))
+	}
+	return textdiff.Unified(hex.Dump(want), hex.Dump(got))
+}
+
+// isText reports whether data looks like text: valid UTF-8 with no NUL
+// bytes.
+func isText(data []byte) bool {
+	return utf8.Valid(data) && bytes.IndexByte(data, 0) < 0
+}
@@ -0,0 +1,171 @@
+package snapshot
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pib/go-debugtools/textdiff"
+)
+
+// reviewEnv is the environment variable that has the same effect as the
+// -review flag.
+const reviewEnv = "REVIEW_SNAPSHOTS"
+
+// receivedSuffix is added to the path of a snapshot to name the file, or
+// directory, holding the value received in its place, awaiting review.
+const receivedSuffix = ".received"
+
+func init() {
+	if flag.Lookup("review") == nil {
+		flag.Bool("review", false, "write values that don't match their snapshots to .received files for review")
+	}
+}
+
+// Reviewing reports whether values that don't match their snapshots are
+// being written to .received files for review, because of the -review
+// flag or the REVIEW_SNAPSHOTS environment variable. Updating takes
+// precedence over it.
+func Reviewing() bool {
+	return setting("review", reviewEnv)
+}
+
+// writeReceived writes text, received in place of the snapshot at path, to
+// the file beside it.
+func writeReceived(path, text string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path+receivedSuffix, encode(text), 0o644)
+}
+
+// writeReceivedTree writes files, received in place of the golden
+// directory golden, to the directory beside it.
+func writeReceivedTree(golden string, files map[string][]byte) error {
+	received := golden + receivedSuffix
+	if err := os.RemoveAll(received); err != nil {
+		return err
+	}
+	for path, data := range files {
+		file := filepath.Join(received, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeReceived removes what was received in place of the snapshot at
+// path, now that it matches.
+func removeReceived(path string) {
+	os.RemoveAll(path + receivedSuffix)
+}
+
+// A Pending snapshot is a value received in place of a snapshot, by a
+// test run with -review, awaiting approval.
+type Pending struct {
+	// Snapshot is the path of the snapshot file, or golden directory.
+	Snapshot string
+	// Received is the path of the value received in its place.
+	Received string
+	// Dir reports whether the snapshot is a golden directory, compared by
+	// MatchDir.
+	Dir bool
+}
+
+// PendingReview returns the snapshots with received values awaiting review
+// in the directory root, such as testdata/snapshots, and its
+// subdirectories, ordered by path.
+func PendingReview(root string) ([]Pending, error) {
+	var pending []Pending
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !strings.HasSuffix(path, receivedSuffix) {
+			return err
+		}
+		pending = append(pending, Pending{
+			Snapshot: strings.TrimSuffix(path, receivedSuffix),
+			Received: path,
+			Dir:      d.IsDir(),
+		})
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Snapshot < pending[j].Snapshot })
+	return pending, err
+}
+
+// Diff returns the differences between the snapshot and the value received
+// in its place: a line diff for a snapshot file, and the files added,
+// removed and changed for a golden directory. A missing snapshot is
+// treated as empty.
+func (p Pending) Diff() (string, error) {
+	if p.Dir {
+		want, err := readTree(os.DirFS(p.Snapshot))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		got, err := readTree(os.DirFS(p.Received))
+		if err != nil {
+			return "", err
+		}
+		return compareTrees(want, got).String(), nil
+	}
+	want, err := readSnapshot(p.Snapshot)
+	if err != nil {
+		return "", err
+	}
+	got, err := readSnapshot(p.Received)
+	if err != nil {
+		return "", err
+	}
+	return textdiff.Unified(want, got), nil
+}
+
+// readSnapshot returns the text of the snapshot file at path, or "" if
+// there is none.
+func readSnapshot(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	_, text, err := decode(data)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+	return text, nil
+}
+
+// Approve replaces the snapshot with the value received in its place.
+func (p Pending) Approve() error {
+	if p.Dir {
+		if err := os.RemoveAll(p.Snapshot); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(p.Received, p.Snapshot); err != nil {
+		return err
+	}
+	recordUpdate(p.Snapshot)
+	return nil
+}
+
+// Reject discards the value received in place of the snapshot.
+func (p Pending) Reject() error {
+	return os.RemoveAll(p.Received)
+}
@@ -0,0 +1,370 @@
+// Package snapshot compares values in tests against golden files kept
+// under testdata/snapshots, so that a test can check a large result, such
+// as a rendered page or a decoded message, without spelling it out in Go.
+// Values are stored as debugtools dumps them, and strings and byte slices
+// as they are.
+//
+// When behavior changes on purpose, run the tests with -update, or with
+// UPDATE_SNAPSHOTS=1 in the environment, to rewrite the snapshots that
+// don't match from the current values instead of editing them by hand:
+//
+//	go test ./... -run TestRender -update
+//
+// Each rewritten file is logged by the test that rewrote it, and
+// WriteSummary lists them all, for calling from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		code := m.Run()
+//		snapshot.WriteSummary(os.Stderr)
+//		os.Exit(code)
+//	}
+//
+// Parts of a value that legitimately change between runs, such as
+// timestamps, UUIDs and port numbers, can be normalized by scrubbers before
+// the snapshot is written or compared, with a Snapshotter:
+//
+//	var snap = snapshot.New(
+//		snapshot.ScrubUUIDs(),
+//		snapshot.ScrubPaths("Session.Token"),
+//	)
+//
+//	func TestLogin(t *testing.T) {
+//		snap.Match(t, login())
+//	}
+//
+// Each snapshot file starts with a line giving the version of its format,
+// such as "snapshot format 2". When the way values are rendered changes,
+// snapshots written in an older format are migrated to the current one as
+// they are read, so they keep matching, and running with -update rewrites
+// them in the current format. Files written before the version line was
+// introduced are read as format 1.
+//
+// Rather than rewriting snapshots wholesale, running with -review, or with
+// REVIEW_SNAPSHOTS=1, writes the value that didn't match beside each
+// snapshot, with the suffix .received, for approving or rejecting one by
+// one, with PendingReview or the snapreview command:
+//
+//	go test ./... -review
+//	go run github.com/pib/go-debugtools/cmd/snapreview approve TestRender
+//
+// The package defines the -update and -review flags itself, so a test
+// package using it must not define flags of those names; it can call
+// Updating and Reviewing instead.
+package snapshot
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	debugtools "github.com/pib/go-debugtools"
+	"github.com/pib/go-debugtools/textdiff"
+)
+
+// dir is the directory snapshots are kept in, relative to the directory of
+// the package being tested.
+var dir = filepath.Join("testdata", "snapshots")
+
+// formatVersion is the version of the format snapshots are written in.
+const formatVersion = 2
+
+// headerPrefix starts the line giving the format of a snapshot file.
+const headerPrefix = "snapshot format "
+
+// migrations convert the text of a snapshot from each format to the next:
+// migrations[0] from format 1 to format 2, and so on. A change to how
+// values are rendered that would alter existing snapshots must increment
+// formatVersion and add a migration here.
+var migrations = []func(text string) string{
+	// Format 2 only adds the version line.
+	func(text string) string { return text },
+}
+
+// updateEnv is the environment variable that has the same effect as the
+// -update flag.
+const updateEnv = "UPDATE_SNAPSHOTS"
+
+func init() {
+	// Leave a flag defined by a package initialized earlier alone.
+	if flag.Lookup("update") == nil {
+		flag.Bool("update", false, "rewrite snapshots that don't match from the current values")
+	}
+}
+
+// Updating reports whether snapshots that don't match are being rewritten,
+// because of the -update flag or the UPDATE_SNAPSHOTS environment variable.
+func Updating() bool {
+	return setting("update", updateEnv)
+}
+
+// setting reports whether the boolean flag or environment variable of the
+// given names is on.
+func setting(flagName, env string) bool {
+	if f := flag.Lookup(flagName); f != nil {
+		if on, _ := strconv.ParseBool(f.Value.String()); on {
+			return true
+		}
+	}
+	on, _ := strconv.ParseBool(os.Getenv(env))
+	return on
+}
+
+var (
+	updatedMu sync.Mutex
+	updated   []string
+)
+
+// Updated returns the snapshot files rewritten or removed so far, in the
+// order they were written.
+func Updated() []string {
+	updatedMu.Lock()
+	defer updatedMu.Unlock()
+	return append([]string(nil), updated...)
+}
+
+// WriteSummary writes the list of snapshot files rewritten so far to w, if
+// any were.
+func WriteSummary(w io.Writer) error {
+	files := Updated()
+	if len(files) == 0 {
+		return nil
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "snapshot: updated %d file(s):\n", len(files))
+	for _, f := range files {
+		fmt.Fprintf(&sb, "\t%s\n", f)
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// A Snapshotter matches values against snapshots with a set of Options.
+type Snapshotter struct {
+	dumpOpts  []debugtools.Option
+	scrubbers []func(string) string
+}
+
+// An Option configures a Snapshotter.
+type Option func(*Snapshotter)
+
+// New returns a Snapshotter configured by opts.
+func New(opts ...Option) *Snapshotter {
+	s := &Snapshotter{}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// DumpOptions renders values with debugtools.Sdump and opts.
+func DumpOptions(opts ...debugtools.Option) Option {
+	return func(s *Snapshotter) {
+		s.dumpOpts = append(s.dumpOpts, opts...)
+	}
+}
+
+// Scrub passes the text of each snapshot through scrub before it is
+// written or compared. Scrubbers run in the order they are given.
+func Scrub(scrub func(text string) string) Option {
+	return func(s *Snapshotter) {
+		s.scrubbers = append(s.scrubbers, scrub)
+	}
+}
+
+// ScrubRegexp replaces the matches of re in the text of each snapshot with
+// replacement, which can refer to submatches as in
+// regexp.Regexp.ReplaceAllString.
+func ScrubRegexp(re *regexp.Regexp, replacement string) Option {
+	return Scrub(func(text string) string {
+		return re.ReplaceAllString(text, replacement)
+	})
+}
+
+// ScrubPaths shows the values at the given paths, written as for
+// debugtools.IgnorePaths, as <redacted>. It has no effect on strings and
+// byte slices, which are stored as they are.
+func ScrubPaths(paths ...string) Option {
+	return DumpOptions(debugtools.DumpRedactPaths(paths...))
+}
+
+var (
+	uuidPattern = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+	timePattern = regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?\b`)
+	portPattern = regexp.MustCompile(`\b(localhost|\d{1,3}(\.\d{1,3}){3}|\[[0-9a-fA-F:]*\]):\d{1,5}\b`)
+)
+
+// ScrubUUIDs replaces UUIDs, such as 123e4567-e89b-12d3-a456-426614174000,
+// with <uuid>.
+func ScrubUUIDs() Option {
+	return ScrubRegexp(uuidPattern, "<uuid>")
+}
+
+// ScrubTimestamps replaces RFC 3339 timestamps, such as
+// 2024-05-01T12:30:00.123Z, and the same with a space for the T, with
+// <time>.
+func ScrubTimestamps() Option {
+	return ScrubRegexp(timePattern, "<time>")
+}
+
+// ScrubPorts replaces the port numbers of local addresses, such as
+// 127.0.0.1:54321 or localhost:8080, with <port>, for servers listening on
+// ports chosen at random.
+func ScrubPorts() Option {
+	return ScrubRegexp(portPattern, "$1:<port>")
+}
+
+// Match checks got against the snapshot of the running test, stored in
+// testdata/snapshots under the test's name, so that each subtest has a
+// snapshot of its own. Values are rendered with debugtools.Sdump and opts,
+// without color. If the snapshot is missing or doesn't match, the test
+// fails with a line diff, unless snapshots are being updated, in which
+// case it is written from got.
+func Match(t testing.TB, got interface{}, opts ...debugtools.Option) {
+	t.Helper()
+	New(DumpOptions(opts...)).Match(t, got)
+}
+
+// Match is like the package-level Match, with s's options.
+func (s *Snapshotter) Match(t testing.TB, got interface{}) {
+	t.Helper()
+	path := filepath.Join(dir, fileName(t.Name())+".snap")
+	text := s.render(got)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("snapshot: %v", err)
+	}
+	var want string
+	if err == nil {
+		var version int
+		version, want, err = decode(data)
+		if err != nil {
+			t.Fatalf("snapshot: %s: %v", path, err)
+		}
+		if want == text {
+			if version < formatVersion && Updating() {
+				if err := write(path, text); err != nil {
+					t.Fatalf("snapshot: %v", err)
+				}
+				t.Logf("snapshot: migrated %s from format %d", path, version)
+			}
+			removeReceived(path)
+			return
+		}
+	}
+	if Updating() {
+		if err := write(path, text); err != nil {
+			t.Fatalf("snapshot: %v", err)
+		}
+		t.Logf("snapshot: updated %s", path)
+		return
+	}
+	if Reviewing() {
+		if err := writeReceived(path, text); err != nil {
+			t.Fatalf("snapshot: %v", err)
+		}
+		t.Errorf("snapshot: %s does not match (-snapshot +got); wrote %s%s for review:\n%s",
+			path, path, receivedSuffix, textdiff.Unified(want, text))
+		return
+	}
+	if err != nil {
+		t.Errorf("snapshot: %s does not exist; run the test with -update to create it", path)
+		return
+	}
+	t.Errorf("snapshot: %s does not match (-snapshot +got); run the test with -update to rewrite it:\n%s",
+		path, textdiff.Unified(want, text))
+}
+
+// render returns the text stored in a snapshot of v, scrubbed.
+func (s *Snapshotter) render(v interface{}) string {
+	var text string
+	switch v := v.(type) {
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		opts := append(s.dumpOpts[:len(s.dumpOpts):len(s.dumpOpts)], debugtools.DumpColor(debugtools.ColorNever))
+		text = debugtools.Sdump(v, opts...)
+	}
+	for _, scrub := range s.scrubbers {
+		text = scrub(text)
+	}
+	return text
+}
+
+// decode returns the format version of the snapshot file data and its text,
+// migrated to the current format.
+func decode(data []byte) (version int, text string, err error) {
+	text = string(data)
+	version = 1
+	if rest, ok := strings.CutPrefix(text, headerPrefix); ok {
+		line, body, _ := strings.Cut(rest, "\n")
+		v, err := strconv.Atoi(line)
+		if err != nil || v < 1 {
+			return 0, "", fmt.Errorf("malformed format line %q", headerPrefix+line)
+		}
+		version, text = v, body
+	}
+	if version > formatVersion {
+		return 0, "", fmt.Errorf("written in format %d, newer than this package's %d", version, formatVersion)
+	}
+	for _, migrate := range migrations[version-1:] {
+		text = migrate(text)
+	}
+	return version, text, nil
+}
+
+// write writes text to the snapshot file at path in the current format,
+// and records that it was updated.
+func write(path, text string) error {
+	return writeFile(path, encode(text))
+}
+
+// encode returns the contents of a snapshot file holding text.
+func encode(text string) []byte {
+	return []byte(headerPrefix + strconv.Itoa(formatVersion) + "\n" + text)
+}
+
+// writeFile writes data to the file at path, creating its directory if
+// needed, and records that it was updated.
+func writeFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	recordUpdate(path)
+	return nil
+}
+
+// recordUpdate records that the snapshot file at path was rewritten or
+// removed.
+func recordUpdate(path string) {
+	updatedMu.Lock()
+	updated = append(updated, path)
+	updatedMu.Unlock()
+}
+
+// fileName turns a test name into a relative file path, keeping the
+// slashes between subtests and replacing characters that are awkward in
+// file names.
+func fileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '_', r == '-', r == '.', r == '/':
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,242 @@
+package debugtools
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pib/go-debugtools/textdiff"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// specialEqual compares values whose type has been given special treatment
+// by an Option, or by this package, as planned in plan. It reports ok=false
+// if v1 and v2 should be compared in the usual way.
+func (s *deepEqualState) specialEqual(v1, v2 reflect.Value, plan typePlan) (eq, ok bool) {
+	if v1.Type() == durationType && s.opts.durationTolerance > 0 {
+		return s.durationEqual(v1, v2), true
+	}
+	if !s.opts.rawTimes {
+		if eq, ok := s.timeEqual(v1, v2); ok {
+			return eq, true
+		}
+	}
+	if v1.Kind() == reflect.String && s.opts.normalizer != nil {
+		if s1, s2 := v1.String(), v2.String(); s1 != s2 && s.opts.normalizer.String(s1) == s.opts.normalizer.String(s2) {
+			s.printf("%+q ~ %+q (differs only by Unicode normalization)\n", s1, s2)
+			return s.report(true, "%+q ~ %+q (differs only by Unicode normalization)", s1, s2), true
+		}
+	}
+	if v1.Type() == jsonNumberType && s.inJSON {
+		// As in DiffJSON, 1 and 1.0 are the same number.
+		if n1, n2 := json.Number(v1.String()), json.Number(v2.String()); n1 != n2 && jsonNumbersEqual(n1, n2) {
+			s.printf("%s ~ %s (same number)\n", n1, n2)
+			return s.report(true, "%s ~ %s (same number)", n1, n2), true
+		}
+	}
+	if asJSON := s.asJSON; asJSON || v1.Type() == rawMessageType {
+		s.asJSON = false
+		if asJSON && !isBytes(v1.Type()) {
+			return false, false
+		}
+		if eq, ok := s.jsonEqual(v1, v2); ok {
+			return eq, true
+		}
+	}
+	if isBig(v1.Type()) {
+		if eq, ok := s.bigEqual(v1, v2); ok {
+			return eq, true
+		}
+	}
+	if plan.valuer && v1.CanInterface() && v2.CanInterface() {
+		if eq, ok := s.valuerEqual(v1, v2); ok {
+			return eq, true
+		}
+	}
+	if isBytes(v1.Type()) && (v1.Len() >= largeBytes || v2.Len() >= largeBytes) {
+		if eq, ok := s.blobEqual(v1, v2); ok {
+			return eq, true
+		}
+	}
+	if v1.Kind() == reflect.String {
+		if s1, s2 := v1.String(), v2.String(); s1 != s2 {
+			if strings.Contains(s1, "\n") || strings.Contains(s2, "\n") {
+				return s.multilineMismatch(s1, s2), true
+			}
+			if len(s1) > longString || len(s2) > longString {
+				return s.longStringMismatch(s1, s2), true
+			}
+		}
+	}
+	return false, false
+}
+
+// multilineMismatch shows the hunks that differ between two multi-line
+// strings.
+func (s *deepEqualState) multilineMismatch(s1, s2 string) bool {
+	hunks := textdiff.Hunks(s1, s2, s.opts.textContext)
+	var removed, added int
+	for _, h := range hunks {
+		for _, e := range h.Edits {
+			switch e.Op {
+			case textdiff.Delete:
+				removed++
+			case textdiff.Insert:
+				added++
+			}
+		}
+	}
+	s.printf("Strings differ, %d lines removed and %d added:\n", removed, added)
+	for _, h := range hunks {
+		s.printf("  %s\n", h.Header())
+		for i := 0; i < len(h.Edits); i++ {
+			if n := s.pairedChanges(h.Edits[i:]); n > 0 {
+				for j := 0; j < n; j++ {
+					del, ins := h.Edits[i+j].Text, h.Edits[i+n+j].Text
+					s.printf("  ~%s\n", s.opts.wordDiff.Highlight(strings.TrimSuffix(del, "\n"), strings.TrimSuffix(ins, "\n")))
+				}
+				i += 2*n - 1
+				continue
+			}
+			e := h.Edits[i]
+			prefix := " "
+			switch e.Op {
+			case textdiff.Delete:
+				prefix = "-"
+			case textdiff.Insert:
+				prefix = "+"
+			}
+			s.printf("  %s%s\n", prefix, strings.TrimSuffix(e.Text, "\n"))
+		}
+	}
+	return s.report(false, "Strings differ, %d lines removed and %d added", removed, added)
+}
+
+// pairedChanges returns n if WithWordDiff is in effect and edits starts with
+// n deleted lines followed by n inserted ones, which are then shown as n
+// changed lines with the differing words highlighted. Otherwise it returns
+// 0.
+func (s *deepEqualState) pairedChanges(edits []textdiff.Edit) int {
+	if s.opts.wordDiff == nil {
+		return 0
+	}
+	n := 0
+	for n < len(edits) && edits[n].Op == textdiff.Delete {
+		n++
+	}
+	if n == 0 || len(edits) < 2*n {
+		return 0
+	}
+	for _, e := range edits[n : 2*n] {
+		if e.Op != textdiff.Insert {
+			return 0
+		}
+	}
+	if len(edits) > 2*n && edits[2*n].Op == textdiff.Insert {
+		return 0
+	}
+	return n
+}
+
+// longStringMismatch describes where two long strings differ instead of
+// printing them in full.
+func (s *deepEqualState) longStringMismatch(s1, s2 string) bool {
+	m := describeStringMismatch(s1, s2)
+	s.printf("Strings of length %d and %d differ, %v\n", len(s1), len(s2), m)
+	if s.opts.wordDiff != nil {
+		s.printf("  diff:  %s\n", s.opts.wordDiff.HighlightContext(s1, s2, stringContext))
+	} else {
+		s.printf("  left:  %s\n", m.left)
+		s.printf("  right: %s\n", m.right)
+	}
+	return s.report(false, "Strings of length %d and %d differ, %v: %s != %s", len(s1), len(s2), m, m.left, m.right)
+}
+
+func (s *deepEqualState) durationEqual(v1, v2 reflect.Value) bool {
+	d1, d2 := time.Duration(v1.Int()), time.Duration(v2.Int())
+	var diff uint64
+	if d1 > d2 {
+		diff = uint64(d1) - uint64(d2)
+	} else {
+		diff = uint64(d2) - uint64(d1)
+	}
+	tol := s.opts.durationTolerance
+	if diff <= uint64(tol) {
+		s.printf("%v ~ %v (within %v)\n", d1, d2, tol)
+		return s.report(true, "%v ~ %v (within %v)", d1, d2, tol)
+	}
+	s.printf("%v != %v (differ by more than %v)\n", d1, d2, tol)
+	return s.report(false, "%v != %v (differ by more than %v)", d1, d2, tol)
+}
+
+// timeEqual compares time.Time and time.Duration values as the general
+// comparison would, but shows them in the trace as RFC 3339 timestamps
+// and as durations like 1h23m0s rather than by their internals.
+func (s *deepEqualState) timeEqual(v1, v2 reflect.Value) (eq, ok bool) {
+	switch v1.Type() {
+	case durationType:
+		d1, d2 := time.Duration(v1.Int()), time.Duration(v2.Int())
+		if !s.observed() {
+			return d1 == d2, true
+		}
+		if d1 == d2 {
+			s.printf("%v == %v\n", d1, d2)
+			return s.report(true, "%v == %v", d1, d2), true
+		}
+		s.printf("%v != %v\n", d1, d2)
+		return s.report(false, "%v != %v", d1, d2), true
+	case timeType:
+		if !v1.CanInterface() || !v2.CanInterface() {
+			return false, false
+		}
+		t1, t2 := v1.Interface().(time.Time), v2.Interface().(time.Time)
+		if !s.observed() {
+			return reflect.DeepEqual(t1, t2), true
+		}
+		f1, f2 := t1.Format(time.RFC3339Nano), t2.Format(time.RFC3339Nano)
+		switch {
+		case reflect.DeepEqual(t1, t2):
+			s.printf("%s == %s\n", f1, f2)
+			return s.report(true, "%s == %s", f1, f2), true
+		case t1.Equal(t2):
+			s.printf("%s != %s (same instant, but different location or monotonic clock reading)\n", f1, f2)
+			return s.report(false, "%s != %s (same instant, but different location or monotonic clock reading)", f1, f2), true
+		}
+		s.printf("%s != %s\n", f1, f2)
+		return s.report(false, "%s != %s", f1, f2), true
+	}
+	return false, false
+}
+
+// chanEqual compares two channels according to the ChanPolicy, naming the
+// policy in the trace.
+func (s *deepEqualState) chanEqual(v1, v2 reflect.Value) bool {
+	p := s.opts.chanPolicy
+	var eq bool
+	var why string
+	switch p {
+	case ChanIgnore:
+		eq, why = true, "Channels ignored, so equal"
+	case ChanNilness:
+		eq = v1.IsNil() == v2.IsNil()
+		if eq {
+			why = "Both channels nil or both non-nil, so equal"
+		} else {
+			why = "One of the channels is nil, so not equal"
+		}
+	default:
+		eq = v1.Pointer() == v2.Pointer()
+		if eq {
+			why = "Same channel, so equal"
+		} else {
+			why = "Different channels, so not equal"
+		}
+	}
+	s.printf("%s (%v)\n", why, p)
+	return s.report(eq, "%s (%v)", why, p)
+}
@@ -0,0 +1,65 @@
+package debugtools
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// valuerEqual compares two driver.Valuers by the values they would store in
+// a database, so that two invalid sql.NullStrings are equal whatever their
+// String fields hold. It reports ok=false if either Value call fails, in
+// which case the values are compared structurally.
+func (s *deepEqualState) valuerEqual(v1, v2 reflect.Value) (eq, ok bool) {
+	d1, err1 := v1.Interface().(driver.Valuer).Value()
+	d2, err2 := v2.Interface().(driver.Valuer).Value()
+	if err1 != nil || err2 != nil {
+		s.printf("Value() failed for %s, comparing structurally: %v, %v\n", v1.Type(), err1, err2)
+		return false, false
+	}
+	l1, l2 := driverValueString(d1), driverValueString(d2)
+	if driverValuesEqual(d1, d2) {
+		s.printf("%s == %s (%s)\n", l1, l2, v1.Type())
+		return s.report(true, "%s == %s (%s)", l1, l2, v1.Type()), true
+	}
+	s.printf("%s != %s (%s)\n", l1, l2, v1.Type())
+	return s.report(false, "%s != %s (%s)", l1, l2, v1.Type()), true
+}
+
+func driverValuesEqual(d1, d2 driver.Value) bool {
+	switch x := d1.(type) {
+	case []byte:
+		y, ok := d2.([]byte)
+		return ok && bytes.Equal(x, y)
+	case time.Time:
+		y, ok := d2.(time.Time)
+		return ok && x.Equal(y)
+	}
+	if _, ok := d2.([]byte); ok {
+		return false
+	}
+	// A custom Valuer may return a value that == would panic on, such as
+	// a slice or a map.
+	if !reflect.ValueOf(d1).Comparable() || !reflect.ValueOf(d2).Comparable() {
+		return reflect.DeepEqual(d1, d2)
+	}
+	return d1 == d2
+}
+
+// driverValueString formats a driver.Value the way it would be thought of
+// in SQL terms.
+func driverValueString(d driver.Value) string {
+	switch x := d.(type) {
+	case nil:
+		return "NULL"
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case []byte:
+		return fmt.Sprintf("%q", x)
+	}
+	return fmt.Sprintf("%#v", d)
+}
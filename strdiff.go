@@ -0,0 +1,128 @@
+package debugtools
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// longString is the length above which mismatched strings are
+	// described by where they differ rather than printed in full.
+	longString = 64
+	// stringContext is the number of bytes shown either side of the first
+	// difference.
+	stringContext = 24
+	// maxEditCells bounds the work done computing an edit distance.
+	maxEditCells = 1 << 24
+)
+
+// stringMismatch describes how two unequal strings differ: the byte offset
+// of the first difference, a window of context around it on each side, and
+// the edit distance between them.
+type stringMismatch struct {
+	offset      int
+	left, right string
+	distance    int // -1 if too expensive to compute
+}
+
+func describeStringMismatch(s1, s2 string) stringMismatch {
+	prefix := commonPrefix(s1, s2)
+	suffix := commonSuffix(s1[prefix:], s2[prefix:])
+	return stringMismatch{
+		offset:   prefix,
+		left:     stringWindow(s1, prefix),
+		right:    stringWindow(s2, prefix),
+		distance: levenshtein(s1[prefix:len(s1)-suffix], s2[prefix:len(s2)-suffix]),
+	}
+}
+
+func (m stringMismatch) String() string {
+	dist := "not computed, strings too different"
+	if m.distance >= 0 {
+		dist = fmt.Sprint(m.distance)
+	}
+	return fmt.Sprintf("first difference at byte %d, edit distance %s", m.offset, dist)
+}
+
+// commonPrefix returns the length of the longest common prefix of s1 and s2
+// that ends on a rune boundary.
+func commonPrefix(s1, s2 string) int {
+	n := 0
+	for n < len(s1) && n < len(s2) && s1[n] == s2[n] {
+		n++
+	}
+	for n > 0 && n < len(s1) && !utf8.RuneStart(s1[n]) {
+		n--
+	}
+	return n
+}
+
+// commonSuffix returns the length of the longest common suffix of s1 and s2
+// that starts on a rune boundary.
+func commonSuffix(s1, s2 string) int {
+	n := 0
+	for n < len(s1) && n < len(s2) && s1[len(s1)-1-n] == s2[len(s2)-1-n] {
+		n++
+	}
+	for n > 0 && !utf8.RuneStart(s1[len(s1)-n]) {
+		n--
+	}
+	return n
+}
+
+// stringWindow quotes the part of s around offset, marking elided text.
+func stringWindow(s string, offset int) string {
+	start, end := offset-stringContext, offset+stringContext
+	if start < 0 {
+		start = 0
+	}
+	if end > len(s) {
+		end = len(s)
+	}
+	for start > 0 && !utf8.RuneStart(s[start]) {
+		start--
+	}
+	for end < len(s) && !utf8.RuneStart(s[end]) {
+		end++
+	}
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	fmt.Fprintf(&b, "%q", s[start:end])
+	if end < len(s) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
+
+// levenshtein returns the number of rune insertions, deletions and
+// substitutions needed to turn s1 into s2, or -1 if that would take more
+// than maxEditCells steps to work out.
+func levenshtein(s1, s2 string) int {
+	r1, r2 := []rune(s1), []rune(s2)
+	if len(r1) == 0 || len(r2) == 0 {
+		return len(r1) + len(r2)
+	}
+	if len(r1)*len(r2) > maxEditCells {
+		return -1
+	}
+	prev := make([]int, len(r2)+1)
+	cur := make([]int, len(r2)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(r1); i++ {
+		cur[0] = i
+		for j := 1; j <= len(r2); j++ {
+			cost := 1
+			if r1[i-1] == r2[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(r2)]
+}
@@ -0,0 +1,27 @@
+package debugtools
+
+import "reflect"
+
+// A FieldChange records the change to a single field, element or map entry
+// found by DiffStructs.
+type FieldChange struct {
+	// FieldPath is the path to the change, such as ".Server.Ports[1]".
+	FieldPath string
+	// Old and New hold the values on each side. Old is nil for an
+	// insertion and New for a deletion; both are nil for unexported values.
+	Old, New interface{}
+}
+
+// DiffStructs compares a and b like Diff and returns a flat list of the
+// changes from a to b, in traversal order, with one entry per changed leaf.
+// It suits audit logging, such as recording what changed when a
+// configuration is reloaded; use Diff to get the whole tree.
+func DiffStructs[T any](a, b T, opts ...Option) []FieldChange {
+	// Both values have type T, so this can't fail.
+	tree, _ := DiffValues(reflect.ValueOf(&a).Elem(), reflect.ValueOf(&b).Elem(), opts...)
+	var changes []FieldChange
+	for _, n := range tree.Leaves() {
+		changes = append(changes, FieldChange{FieldPath: n.Path, Old: n.Left, New: n.Right})
+	}
+	return changes
+}
@@ -0,0 +1,95 @@
+package debugtools
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A DiffSummary counts the changes in a DiffTree.
+type DiffSummary struct {
+	Total int
+	// ByKind counts the changes of each kind.
+	ByKind map[ChangeKind]int
+	// ByPrefix counts the changes under each top-level path, such as
+	// ".Inventory", most changes first.
+	ByPrefix []PrefixCount
+}
+
+// A PrefixCount is the number of changes under a top-level path.
+type PrefixCount struct {
+	Prefix string
+	Count  int
+}
+
+// Summary counts the changes in the tree by kind and by top-level path.
+func (d *DiffTree) Summary() *DiffSummary {
+	sum := &DiffSummary{ByKind: make(map[ChangeKind]int)}
+	index := make(map[string]int)
+	for _, n := range d.Leaves() {
+		sum.Total++
+		sum.ByKind[n.Kind]++
+		p := displayPath(firstStep(n.Path))
+		i, ok := index[p]
+		if !ok {
+			i = len(sum.ByPrefix)
+			index[p] = i
+			sum.ByPrefix = append(sum.ByPrefix, PrefixCount{Prefix: p})
+		}
+		sum.ByPrefix[i].Count++
+	}
+	sort.SliceStable(sum.ByPrefix, func(i, j int) bool {
+		return sum.ByPrefix[i].Count > sum.ByPrefix[j].Count
+	})
+	return sum
+}
+
+// String formats the summary in two lines, such as
+//
+//	15 changes: 12 modified, 2 inserted, 1 deleted
+//	12 under .Inventory, 3 under .Pricing
+func (s *DiffSummary) String() string {
+	if s.Total == 0 {
+		return "No changes\n"
+	}
+	buf := &bytes.Buffer{}
+	if s.Total == 1 {
+		buf.WriteString("1 change: ")
+	} else {
+		fmt.Fprintf(buf, "%d changes: ", s.Total)
+	}
+	var kinds []string
+	for _, k := range []ChangeKind{Modified, Inserted, Deleted} {
+		if n := s.ByKind[k]; n > 0 {
+			kinds = append(kinds, fmt.Sprintf("%d %v", n, k))
+		}
+	}
+	buf.WriteString(strings.Join(kinds, ", ") + "\n")
+	var prefixes []string
+	for _, p := range s.ByPrefix {
+		prefixes = append(prefixes, fmt.Sprintf("%d under %s", p.Count, p.Prefix))
+	}
+	buf.WriteString(strings.Join(prefixes, ", ") + "\n")
+	return buf.String()
+}
+
+// firstStep returns the first step of path, such as ".User" for
+// ".User.Name" or `["a.b"]` for `["a.b"][0]`.
+func firstStep(path string) string {
+	quoted := false
+	for i := 1; i < len(path); i++ {
+		switch c := path[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case path[0] == '[' && c == ']':
+			return path[:i+1]
+		case path[0] == '.' && (c == '.' || c == '['):
+			return path[:i]
+		}
+	}
+	return path
+}
@@ -0,0 +1,28 @@
+package debugtools
+
+import (
+	"reflect"
+	"strings"
+)
+
+// tagKey is the struct tag holding per-field comparison flags, as in
+// `deepequal:"json"`.
+const tagKey = "deepequal"
+
+// dumpTagKey is the struct tag holding per-field flags for Dump, as in
+// `dump:"redact"`.
+const dumpTagKey = "dump"
+
+// hasTagFlag reports whether the field's tag for key includes flag.
+func hasTagFlag(f reflect.StructField, key, flag string) bool {
+	tag, ok := f.Tag.Lookup(key)
+	if !ok {
+		return false
+	}
+	for _, t := range strings.Split(tag, ",") {
+		if strings.TrimSpace(t) == flag {
+			return true
+		}
+	}
+	return false
+}
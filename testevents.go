@@ -0,0 +1,223 @@
+package debugtools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// A durationThreshold is the change in the duration of a test that
+// DiffTestEvents reports: by more than fraction, and by more than min.
+type durationThreshold struct {
+	fraction float64
+	min      time.Duration
+}
+
+// defaultTestThreshold is the change in duration below which
+// DiffTestEvents considers it noise.
+var defaultTestThreshold = durationThreshold{fraction: 0.2, min: 100 * time.Millisecond}
+
+// TestDurationThreshold sets the slowdown a test must exceed for
+// DiffTestEvents to report it: by more than fraction of its old duration,
+// such as 0.5 for 50%, and by more than min, so that tests taking a few
+// milliseconds aren't reported for jitter. The default is 20% and 100ms.
+func TestDurationThreshold(fraction float64, min time.Duration) Option {
+	return func(o *options) {
+		o.testThreshold = &durationThreshold{fraction: fraction, min: min}
+	}
+}
+
+// A testEvent is a line of the output of go test -json.
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+}
+
+// testRuns holds the outcomes of the runs of a test in a stream of events.
+type testRuns struct {
+	pkg, name string
+	outcomes  []string
+	elapsed   []float64
+}
+
+// status returns the outcome of the test's runs: "pass", "fail" or "skip"
+// if every run had the same one, and "flaky" if some passed and some
+// failed.
+func (r *testRuns) status() string {
+	seen := make(map[string]bool)
+	for _, o := range r.outcomes {
+		seen[o] = true
+	}
+	switch {
+	case seen["pass"] && seen["fail"]:
+		return "flaky"
+	case seen["fail"]:
+		return "fail"
+	case seen["pass"]:
+		return "pass"
+	}
+	return "skip"
+}
+
+// describe returns the status of the test's runs, with how many failed if
+// it is flaky, such as "flaky (2 of 5 runs failed)".
+func (r *testRuns) describe() string {
+	s := r.status()
+	if s != "flaky" {
+		return s
+	}
+	failed := 0
+	for _, o := range r.outcomes {
+		if o == "fail" {
+			failed++
+		}
+	}
+	return fmt.Sprintf("flaky (%d of %d runs failed)", failed, len(r.outcomes))
+}
+
+// DiffTestEvents parses old and new, two streams of events written by go
+// test -json, and compares the tests they ran. The result holds a node for
+// each test that changed, addressed by its package and name as keys of a
+// JSON document, as in
+//
+//	["example.com/shop"].TestCheckout
+//	["example.com/shop"]["TestCheckout/empty_cart"]
+//
+// with a Modified leaf at .status beneath it if its outcome changed, such
+// as from "pass" to "fail", and one at .elapsed if it became slower than
+// set by TestDurationThreshold, such as
+//
+//	~ ["example.com/shop"].TestCheckout.elapsed: 0.20s → 1.50s (+650.0%), regression
+//
+// and an Inserted or Deleted leaf for a test only run on one side. A test
+// run several times, with -count, is represented by the median of its
+// durations, and is "flaky" if some of its runs passed and others failed,
+// so that a test that has become flaky shows as a change of status. Lines
+// other than test events, such as build output, are ignored, but it is an
+// error for either stream to contain none.
+func DiffTestEvents(old, new []byte, opts ...Option) (*DiffTree, error) {
+	o := newOptions(opts)
+	threshold := defaultTestThreshold
+	if o.testThreshold != nil {
+		threshold = *o.testThreshold
+	}
+	r1, err := parseTestEvents(old)
+	if err != nil {
+		return nil, fmt.Errorf("debugtools: parsing old test events: %w", err)
+	}
+	r2, err := parseTestEvents(new)
+	if err != nil {
+		return nil, fmt.Errorf("debugtools: parsing new test events: %w", err)
+	}
+	keys := make([]string, 0, len(r1)+len(r2))
+	for k := range r1 {
+		keys = append(keys, k)
+	}
+	for k := range r2 {
+		if _, ok := r1[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	root := &Difference{}
+	for _, k := range keys {
+		t1, ok1 := r1[k]
+		t2, ok2 := r2[k]
+		switch {
+		case !ok1:
+			root.Children = append(root.Children, &Difference{Path: testPath(t2), Kind: Inserted, Message: "only in new", Right: t2.status(), RightText: t2.describe()})
+			continue
+		case !ok2:
+			root.Children = append(root.Children, &Difference{Path: testPath(t1), Kind: Deleted, Message: "only in old", Left: t1.status(), LeftText: t1.describe()})
+			continue
+		}
+		path := testPath(t1)
+		node := &Difference{Path: path}
+		if s1, s2 := t1.status(), t2.status(); s1 != s2 {
+			node.Children = append(node.Children, &Difference{
+				Path:      path + jsonKeyStep("status"),
+				Kind:      Modified,
+				Message:   fmt.Sprintf("%s → %s", t1.describe(), t2.describe()),
+				Left:      s1,
+				LeftText:  t1.describe(),
+				Right:     s2,
+				RightText: t2.describe(),
+			})
+		}
+		e1, e2 := median(t1.elapsed), median(t2.elapsed)
+		change := relativeChange(e1, e2)
+		if change > threshold.fraction && time.Duration((e2-e1)*float64(time.Second)) > threshold.min {
+			node.Children = append(node.Children, &Difference{
+				Path:      path + jsonKeyStep("elapsed"),
+				Kind:      Modified,
+				Message:   fmt.Sprintf("%s → %s (%+.1f%%), regression", testSeconds(e1), testSeconds(e2), 100*change),
+				Left:      e1,
+				LeftText:  testSeconds(e1),
+				Right:     e2,
+				RightText: testSeconds(e2),
+			})
+		}
+		if len(node.Children) > 0 {
+			root.Children = append(root.Children, node)
+		}
+	}
+	if len(root.Children) == 0 {
+		return &DiffTree{}, nil
+	}
+	return &DiffTree{Root: root}, nil
+}
+
+// parseTestEvents returns the runs of each test in the output of go test
+// -json, keyed by package and test name.
+func parseTestEvents(out []byte) (map[string]*testRuns, error) {
+	runs := make(map[string]*testRuns)
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if !bytes.HasPrefix(line, []byte("{")) {
+			continue
+		}
+		var e testEvent
+		if err := json.Unmarshal(line, &e); err != nil || e.Test == "" {
+			continue
+		}
+		switch e.Action {
+		case "pass", "fail", "skip":
+		default:
+			continue
+		}
+		key := e.Package + "\x00" + e.Test
+		r := runs[key]
+		if r == nil {
+			r = &testRuns{pkg: e.Package, name: e.Test}
+			runs[key] = r
+		}
+		r.outcomes = append(r.outcomes, e.Action)
+		r.elapsed = append(r.elapsed, e.Elapsed)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, errors.New("no test results")
+	}
+	return runs, nil
+}
+
+// testPath returns the path of a test in the result of DiffTestEvents.
+func testPath(r *testRuns) string {
+	return jsonKeyStep(r.pkg) + jsonKeyStep(r.name)
+}
+
+// testSeconds formats a duration in seconds as go test does, such as
+// "1.25s".
+func testSeconds(s float64) string {
+	return fmt.Sprintf("%.2fs", s)
+}
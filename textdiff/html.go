@@ -0,0 +1,25 @@
+package textdiff
+
+import (
+	"html"
+	"strings"
+)
+
+// HTML returns an HTML fragment showing how b differs from a rune by rune,
+// with deleted text in <del> elements and inserted text in <ins> elements.
+// All text is escaped, so the fragment can be embedded in any page.
+func HTML(a, b string) string {
+	var sb strings.Builder
+	for _, e := range Runes(a, b) {
+		text := html.EscapeString(e.Text)
+		switch e.Op {
+		case Delete:
+			sb.WriteString("<del>" + text + "</del>")
+		case Insert:
+			sb.WriteString("<ins>" + text + "</ins>")
+		default:
+			sb.WriteString(text)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,159 @@
+package textdiff
+
+// differ finds a shortest edit script between two sequences using the
+// linear space variant of Myers' algorithm, recording which elements of
+// each side are matched. eq(i, j) reports whether the i'th element of the
+// first sequence equals the j'th of the second.
+type differ struct {
+	eq     func(i, j int) bool
+	ma, mb []bool
+}
+
+func diff[T comparable](a, b []T) *differ {
+	return newDiffer(len(a), len(b), func(i, j int) bool { return a[i] == b[j] })
+}
+
+func newDiffer(n, m int, eq func(i, j int) bool) *differ {
+	d := &differ{eq: eq, ma: make([]bool, n), mb: make([]bool, m)}
+	d.compare(0, n, 0, m)
+	return d
+}
+
+// Align returns the shortest edit script turning a sequence of n elements
+// into one of m elements, as one Op per element, with deletions before
+// insertions within each changed region. eq(i, j) reports whether the i'th
+// element of the first sequence equals the j'th of the second.
+func Align(n, m int, eq func(i, j int) bool) []Op {
+	return newDiffer(n, m, eq).ops()
+}
+
+func (d *differ) match(i, j int) {
+	d.ma[i] = true
+	d.mb[j] = true
+}
+
+// compare matches up a[alo:ahi] and b[blo:bhi].
+func (d *differ) compare(alo, ahi, blo, bhi int) {
+	for alo < ahi && blo < bhi && d.eq(alo, blo) {
+		d.match(alo, blo)
+		alo++
+		blo++
+	}
+	for alo < ahi && blo < bhi && d.eq(ahi-1, bhi-1) {
+		ahi--
+		bhi--
+		d.match(ahi, bhi)
+	}
+	if alo == ahi || blo == bhi {
+		return
+	}
+	x, y, ok := d.bisect(alo, ahi, blo, bhi)
+	if !ok {
+		return
+	}
+	d.compare(alo, x, blo, y)
+	d.compare(x, ahi, y, bhi)
+}
+
+// bisect finds the middle snake of a[alo:ahi] and b[blo:bhi] by running the
+// greedy search forwards and backwards at once, and returns the point where
+// the two paths meet. It reports false if the ranges have nothing in
+// common.
+func (d *differ) bisect(alo, ahi, blo, bhi int) (int, int, bool) {
+	n, m := ahi-alo, bhi-blo
+	maxD := (n + m + 1) / 2
+	off := maxD
+	size := 2*maxD + 2
+	v1 := make([]int, size)
+	v2 := make([]int, size)
+	for i := range v1 {
+		v1[i] = -1
+		v2[i] = -1
+	}
+	v1[off+1] = 0
+	v2[off+1] = 0
+	delta := n - m
+	front := delta%2 != 0
+	var k1start, k1end, k2start, k2end int
+	for step := 0; step < maxD; step++ {
+		for k1 := -step + k1start; k1 <= step-k1end; k1 += 2 {
+			i := off + k1
+			var x1 int
+			if k1 == -step || (k1 != step && v1[i-1] < v1[i+1]) {
+				x1 = v1[i+1]
+			} else {
+				x1 = v1[i-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && d.eq(alo+x1, blo+y1) {
+				x1++
+				y1++
+			}
+			v1[i] = x1
+			switch {
+			case x1 > n:
+				k1end += 2
+			case y1 > m:
+				k1start += 2
+			case front:
+				j := off + delta - k1
+				if j >= 0 && j < size && v2[j] != -1 && x1 >= n-v2[j] {
+					return alo + x1, blo + y1, true
+				}
+			}
+		}
+		for k2 := -step + k2start; k2 <= step-k2end; k2 += 2 {
+			i := off + k2
+			var x2 int
+			if k2 == -step || (k2 != step && v2[i-1] < v2[i+1]) {
+				x2 = v2[i+1]
+			} else {
+				x2 = v2[i-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && d.eq(ahi-x2-1, bhi-y2-1) {
+				x2++
+				y2++
+			}
+			v2[i] = x2
+			switch {
+			case x2 > n:
+				k2end += 2
+			case y2 > m:
+				k2start += 2
+			case !front:
+				j := off + delta - k2
+				if j >= 0 && j < size && v1[j] != -1 {
+					x1 := v1[j]
+					y1 := off + x1 - j
+					if x1 >= n-x2 {
+						return alo + x1, blo + y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// ops returns the edit script as one Op per element, deletions before
+// insertions within each changed region.
+func (d *differ) ops() []Op {
+	ops := make([]Op, 0, len(d.ma)+len(d.mb))
+	i, j := 0, 0
+	for i < len(d.ma) || j < len(d.mb) {
+		switch {
+		case i < len(d.ma) && !d.ma[i]:
+			ops = append(ops, Delete)
+			i++
+		case j < len(d.mb) && !d.mb[j]:
+			ops = append(ops, Insert)
+			j++
+		default:
+			ops = append(ops, Equal)
+			i++
+			j++
+		}
+	}
+	return ops
+}
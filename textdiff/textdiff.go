@@ -0,0 +1,228 @@
+// Package textdiff computes differences between texts, line by line or rune
+// by rune, using Myers' algorithm, and renders them as unified diffs.
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op is the kind of an Edit.
+type Op int
+
+const (
+	// Equal text is present in both texts.
+	Equal Op = iota
+	// Insert text is only present in the second text.
+	Insert
+	// Delete text is only present in the first text.
+	Delete
+)
+
+func (op Op) String() string {
+	switch op {
+	case Equal:
+		return "equal"
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	}
+	return fmt.Sprintf("Op(%d)", int(op))
+}
+
+// An Edit is a piece of one or both texts.
+type Edit struct {
+	Op   Op
+	Text string
+}
+
+// Lines returns the edits that turn a into b, with one Edit per line. Each
+// line's Text includes its trailing newline, if it has one.
+func Lines(a, b string) []Edit {
+	la, lb := SplitLines(a), SplitLines(b)
+	d := diff(la, lb)
+	var edits []Edit
+	i, j := 0, 0
+	for _, op := range d.ops() {
+		switch op {
+		case Equal:
+			edits = append(edits, Edit{Equal, la[i]})
+			i++
+			j++
+		case Delete:
+			edits = append(edits, Edit{Delete, la[i]})
+			i++
+		case Insert:
+			edits = append(edits, Edit{Insert, lb[j]})
+			j++
+		}
+	}
+	return edits
+}
+
+// Runes returns the edits that turn a into b rune by rune, with runs of
+// runes sharing an Op merged into a single Edit.
+func Runes(a, b string) []Edit {
+	ra, rb := []rune(a), []rune(b)
+	d := diff(ra, rb)
+	var edits []Edit
+	var cur []rune
+	curOp := Equal
+	flush := func() {
+		if len(cur) > 0 {
+			edits = append(edits, Edit{curOp, string(cur)})
+			cur = cur[:0]
+		}
+	}
+	i, j := 0, 0
+	for _, op := range d.ops() {
+		if op != curOp {
+			flush()
+			curOp = op
+		}
+		switch op {
+		case Equal:
+			cur = append(cur, ra[i])
+			i++
+			j++
+		case Delete:
+			cur = append(cur, ra[i])
+			i++
+		case Insert:
+			cur = append(cur, rb[j])
+			j++
+		}
+	}
+	flush()
+	return edits
+}
+
+// SplitLines splits s after each newline. A final line without a newline is
+// included; an empty s has no lines.
+func SplitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// DefaultContext is the number of unchanged lines Unified shows around each
+// change, as diff -u does.
+const DefaultContext = 3
+
+// A Hunk is a run of changed lines along with the unchanged lines around
+// them.
+type Hunk struct {
+	// AStart and BStart are the zero-based line numbers in each text at
+	// which the hunk starts, and ALines and BLines the number of lines it
+	// covers there.
+	AStart, ALines int
+	BStart, BLines int
+	Edits          []Edit
+}
+
+// Header returns the hunk's "@@ -l,s +l,s @@" line, without a newline.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.AStart, h.ALines), hunkRange(h.BStart, h.BLines))
+}
+
+// hunkRange formats a line range the way GNU diff does: one-based, with the
+// count left off when it is 1, and the line before the range given when it
+// is empty.
+func hunkRange(start, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// Hunks groups the line edits between a and b into hunks, each with up to n
+// unchanged lines of context either side. Changes separated by no more than
+// 2n unchanged lines share a hunk.
+func Hunks(a, b string, n int) []Hunk {
+	if n < 0 {
+		n = 0
+	}
+	edits := Lines(a, b)
+	var hunks []Hunk
+	// Line numbers in a and b at which each edit starts.
+	ai, bi := make([]int, len(edits)+1), make([]int, len(edits)+1)
+	for i, e := range edits {
+		ai[i+1], bi[i+1] = ai[i], bi[i]
+		if e.Op != Insert {
+			ai[i+1]++
+		}
+		if e.Op != Delete {
+			bi[i+1]++
+		}
+	}
+	for i := 0; i < len(edits); {
+		if edits[i].Op == Equal {
+			i++
+			continue
+		}
+		start := max(i-n, 0)
+		// Extend the hunk while the next change is close enough.
+		end := i
+		for j := i; j < len(edits); j++ {
+			if edits[j].Op != Equal {
+				end = j + 1
+			} else if j-end >= 2*n {
+				break
+			}
+		}
+		stop := min(end+n, len(edits))
+		hunks = append(hunks, Hunk{
+			AStart: ai[start], ALines: ai[stop] - ai[start],
+			BStart: bi[start], BLines: bi[stop] - bi[start],
+			Edits: edits[start:stop],
+		})
+		i = stop
+	}
+	return hunks
+}
+
+// Unified returns a line diff of a and b in the style of diff -u, with
+// DefaultContext lines of context around each change. It returns the empty
+// string if a and b are equal.
+func Unified(a, b string) string {
+	return UnifiedContext(a, b, DefaultContext)
+}
+
+// UnifiedContext is like Unified, but shows n lines of context around each
+// change, as diff -U n does.
+func UnifiedContext(a, b string, n int) string {
+	if a == b {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("--- a\n+++ b\n")
+	for _, h := range Hunks(a, b, n) {
+		sb.WriteString(h.Header())
+		sb.WriteByte('\n')
+		writeEdits(&sb, h.Edits)
+	}
+	return sb.String()
+}
+
+func writeEdits(sb *strings.Builder, edits []Edit) {
+	for _, e := range edits {
+		switch e.Op {
+		case Equal:
+			sb.WriteByte(' ')
+		case Delete:
+			sb.WriteByte('-')
+		case Insert:
+			sb.WriteByte('+')
+		}
+		sb.WriteString(e.Text)
+		if !strings.HasSuffix(e.Text, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package textdiff
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Words returns the edits that turn a into b word by word. Words are runs
+// of letters and digits; runs of spaces and single punctuation characters
+// are tokens of their own. Runs of tokens sharing an Op are merged into a
+// single Edit.
+func Words(a, b string) []Edit {
+	ta, tb := splitWords(a), splitWords(b)
+	d := diff(ta, tb)
+	var edits []Edit
+	i, j := 0, 0
+	for _, op := range d.ops() {
+		var tok string
+		switch op {
+		case Equal:
+			tok = ta[i]
+			i++
+			j++
+		case Delete:
+			tok = ta[i]
+			i++
+		case Insert:
+			tok = tb[j]
+			j++
+		}
+		if n := len(edits); n > 0 && edits[n-1].Op == op {
+			edits[n-1].Text += tok
+		} else {
+			edits = append(edits, Edit{op, tok})
+		}
+	}
+	return edits
+}
+
+func splitWords(s string) []string {
+	var toks []string
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		n := size
+		switch {
+		case isWordRune(r):
+			for n < len(s) {
+				r, size := utf8.DecodeRuneInString(s[n:])
+				if !isWordRune(r) {
+					break
+				}
+				n += size
+			}
+		case unicode.IsSpace(r):
+			for n < len(s) {
+				r, size := utf8.DecodeRuneInString(s[n:])
+				if !unicode.IsSpace(r) {
+					break
+				}
+				n += size
+			}
+		}
+		toks = append(toks, s[:n])
+		s = s[n:]
+	}
+	return toks
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// A Highlighter marks the deleted and inserted parts of a changed line.
+type Highlighter struct {
+	DeleteStart, DeleteEnd string
+	InsertStart, InsertEnd string
+}
+
+var (
+	// Markers highlights changes the way git diff --word-diff=plain does,
+	// as [-deleted-]{+inserted+}.
+	Markers = Highlighter{"[-", "-]", "{+", "+}"}
+	// ANSI highlights deletions in red and insertions in green, for
+	// terminals.
+	ANSI = Highlighter{"\x1b[31m", "\x1b[0m", "\x1b[32m", "\x1b[0m"}
+)
+
+// Highlight returns a single line combining a and b, with the words that
+// differ between them marked by h.
+func (h Highlighter) Highlight(a, b string) string {
+	return h.HighlightContext(a, b, -1)
+}
+
+// HighlightContext is like Highlight, but keeps only n runes of unchanged
+// text either side of each change, replacing the rest with "...". A
+// negative n keeps everything.
+func (h Highlighter) HighlightContext(a, b string, n int) string {
+	edits := Words(a, b)
+	var sb strings.Builder
+	for i, e := range edits {
+		switch e.Op {
+		case Delete:
+			sb.WriteString(h.DeleteStart + e.Text + h.DeleteEnd)
+		case Insert:
+			sb.WriteString(h.InsertStart + e.Text + h.InsertEnd)
+		default:
+			sb.WriteString(elide(e.Text, n, i > 0, i < len(edits)-1))
+		}
+	}
+	return sb.String()
+}
+
+// elide shortens unchanged text to n runes after a preceding change and n
+// runes before a following one.
+func elide(s string, n int, before, after bool) string {
+	r := []rune(s)
+	keep := 0
+	if before {
+		keep += n
+	}
+	if after {
+		keep += n
+	}
+	if n < 0 || len(r) <= keep+3 {
+		return s
+	}
+	var head, tail string
+	if before {
+		head = string(r[:n])
+	}
+	if after {
+		tail = string(r[len(r)-n:])
+	}
+	return head + "..." + tail
+}
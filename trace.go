@@ -0,0 +1,77 @@
+package debugtools
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// cappedBuffer collects the trace, discarding whole writes once the trace
+// would grow past max bytes and counting the lines it discards.
+type cappedBuffer struct {
+	bytes.Buffer
+	max     int
+	full    bool
+	dropped int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if !b.full && b.max > 0 && b.Len()+len(p) > b.max {
+		b.full = true
+	}
+	if b.full {
+		b.dropped += bytes.Count(p, []byte{'\n'})
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// String returns the trace, followed by a note if any of it was discarded.
+func (b *cappedBuffer) String() string {
+	if !b.full {
+		return b.Buffer.String()
+	}
+	if n := b.Len(); n > 0 && b.Bytes()[n-1] != '\n' {
+		b.Buffer.WriteByte('\n')
+	}
+	fmt.Fprintf(&b.Buffer, "... trace truncated, %d more events\n", b.dropped)
+	b.full = false
+	return b.Buffer.String()
+}
+
+// A MismatchLine is the data passed to the template given to
+// WithMismatchTemplate.
+type MismatchLine struct {
+	// Path is the path of the mismatch, empty at the top level.
+	Path string
+	// Kind is "modified", "inserted" or "deleted", as for ChangeKind.
+	Kind string
+	// Message describes the mismatch, as in Result.Message.
+	Message string
+	// Left and Right are the values formatted with %#v, or empty if the
+	// path doesn't exist on that side.
+	Left, Right string
+}
+
+// writeMismatch writes a mismatch line for the current values with the
+// template from the options. If the template fails, the default format is
+// used, followed by the error.
+func (s *deepEqualState) writeMismatch(msg string) {
+	line := MismatchLine{Path: s.currentPath(), Kind: Modified.String(), Message: msg}
+	switch {
+	case !s.v1.IsValid():
+		line.Kind = Inserted.String()
+	case !s.v2.IsValid():
+		line.Kind = Deleted.String()
+	}
+	_, line.Left = diffValue(s.v1)
+	_, line.Right = diffValue(s.v2)
+	buf := &bytes.Buffer{}
+	if err := s.opts.mismatchTemplate.Execute(buf, line); err != nil {
+		fmt.Fprintf(s.w, "%s: %s (template error: %v)\n", displayPath(line.Path), msg, err)
+		return
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	s.w.Write(buf.Bytes())
+}
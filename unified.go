@@ -0,0 +1,65 @@
+package debugtools
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/pib/go-debugtools/textdiff"
+)
+
+// UnifiedFormatter renders a DiffTree in the unified format of diff -u, so
+// that tools that parse diff output can read it. Each side is treated as a
+// text with a line "path: value" for each of its changed values, and each
+// change is a hunk of those lines. Like diff, it writes nothing if there
+// are no differences; ExitStatus gives diff's exit status.
+type UnifiedFormatter struct {
+	// LeftLabel and RightLabel are written in the "---" and "+++" headers,
+	// "a" and "b" if empty.
+	LeftLabel, RightLabel string
+}
+
+func (f UnifiedFormatter) FormatDiff(w io.Writer, d *DiffTree) error {
+	if d.Root == nil {
+		return nil
+	}
+	left, right := f.LeftLabel, f.RightLabel
+	if left == "" {
+		left = "a"
+	}
+	if right == "" {
+		right = "b"
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString("--- " + left + "\n+++ " + right + "\n")
+	// Line numbers in each side's text.
+	var ai, bi int
+	for _, n := range d.Leaves() {
+		h := textdiff.Hunk{AStart: ai, BStart: bi}
+		if n.Kind != Inserted {
+			h.ALines = 1
+		}
+		if n.Kind != Deleted {
+			h.BLines = 1
+		}
+		bw.WriteString(h.Header() + "\n")
+		if h.ALines > 0 {
+			bw.WriteString("-" + displayPath(n.Path) + ": " + n.LeftText + "\n")
+		}
+		if h.BLines > 0 {
+			bw.WriteString("+" + displayPath(n.Path) + ": " + n.RightText + "\n")
+		}
+		ai += h.ALines
+		bi += h.BLines
+	}
+	return bw.Flush()
+}
+
+// ExitStatus returns the exit status diff would have for the comparison:
+// 0 if the values were equal and 1 if not. (diff's status 2, for trouble,
+// corresponds to an error from Diff.)
+func (d *DiffTree) ExitStatus() int {
+	if d.Equal() {
+		return 0
+	}
+	return 1
+}
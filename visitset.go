@@ -0,0 +1,94 @@
+package debugtools
+
+// smallVisits is the number of visits a visitSet holds before it moves
+// them to a hash table.
+const smallVisits = 8
+
+// A visitSet is the set of comparisons in progress that deepValueEqual
+// checks to short circuit cycles. Most comparisons record only a few,
+// which are kept in an array searched in order; past smallVisits, they
+// move to an open-addressed hash table keyed on the packed addresses. The
+// zero value is an empty set.
+type visitSet struct {
+	small [smallVisits]visit
+	n     int
+	// table, once in use, holds every visit, with a nil typ marking an empty
+	// slot. Its length is a power of two.
+	table []visit
+}
+
+// hash mixes the addresses of v into an index for the table.
+func (v visit) hash() uintptr {
+	h := uint64(v.a1)*0x9e3779b97f4a7c15 ^ uint64(v.a2)*0xc2b2ae3d27d4eb4f
+	return uintptr(h ^ h>>29)
+}
+
+// has reports whether v is in the set.
+func (s *visitSet) has(v visit) bool {
+	if len(s.table) == 0 {
+		for i := 0; i < s.n; i++ {
+			if s.small[i] == v {
+				return true
+			}
+		}
+		return false
+	}
+	mask := uintptr(len(s.table) - 1)
+	for i := v.hash() & mask; s.table[i].typ != nil; i = (i + 1) & mask {
+		if s.table[i] == v {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds v, which must not be in the set already.
+func (s *visitSet) add(v visit) {
+	switch {
+	case len(s.table) == 0 && s.n < smallVisits:
+		s.small[s.n] = v
+		s.n++
+		return
+	case len(s.table) == 0:
+		// Move to the table, reusing the one kept by reset if there is one.
+		if cap(s.table) > 0 {
+			s.table = s.table[:cap(s.table)]
+			clear(s.table)
+		} else {
+			s.table = make([]visit, 4*smallVisits)
+		}
+		for _, old := range s.small[:s.n] {
+			s.insert(old)
+		}
+	case 4*(s.n+1) > 3*len(s.table):
+		old := s.table
+		s.table = make([]visit, 2*len(old))
+		for _, o := range old {
+			if o.typ != nil {
+				s.insert(o)
+			}
+		}
+	}
+	s.insert(v)
+	s.n++
+}
+
+// insert puts v in the table, without counting it.
+func (s *visitSet) insert(v visit) {
+	mask := uintptr(len(s.table) - 1)
+	i := v.hash() & mask
+	for s.table[i].typ != nil {
+		i = (i + 1) & mask
+	}
+	s.table[i] = v
+}
+
+// reset empties the set, keeping the memory of its table for reuse unless
+// the table has grown past max entries.
+func (s *visitSet) reset(max int) {
+	table := s.table[:0]
+	if cap(table) > max {
+		table = nil
+	}
+	*s = visitSet{table: table}
+}
@@ -0,0 +1,313 @@
+package debugtools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DiffYAML parses the YAML streams a and b and compares them structurally,
+// like DiffJSON, reporting each difference by its path within the document.
+// Aliases are followed to the values they refer to, and merge keys (<<) are
+// expanded, so a value spelled out in one document matches an alias to an
+// equal value in the other; a difference reached through an alias says so.
+// If either stream holds more than one document, paths start with the
+// document's index, as in "[1].spec.replicas". IgnorePaths and OnlyPaths
+// are honored; other options are not.
+func DiffYAML(a, b []byte, opts ...Option) (*DiffTree, error) {
+	docs1, err := decodeYAML(a)
+	if err != nil {
+		return nil, fmt.Errorf("debugtools: parsing first YAML stream: %w", err)
+	}
+	docs2, err := decodeYAML(b)
+	if err != nil {
+		return nil, fmt.Errorf("debugtools: parsing second YAML stream: %w", err)
+	}
+	y := &yamlDiffer{opts: newOptions(opts), visited: make(map[[2]*yaml.Node]bool)}
+	if len(docs1) == 1 && len(docs2) == 1 {
+		return &DiffTree{Root: y.diff("", docs1[0], docs2[0], "")}, nil
+	}
+	root := &Difference{}
+	for i := 0; i < len(docs1) || i < len(docs2); i++ {
+		var n1, n2 *yaml.Node
+		if i < len(docs1) {
+			n1 = docs1[i]
+		}
+		if i < len(docs2) {
+			n2 = docs2[i]
+		}
+		if d := y.diff("["+strconv.Itoa(i)+"]", n1, n2, ""); d != nil {
+			root.Children = append(root.Children, d)
+		}
+	}
+	if len(root.Children) == 0 {
+		return &DiffTree{}, nil
+	}
+	return &DiffTree{Root: root}, nil
+}
+
+func decodeYAML(b []byte) ([]*yaml.Node, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	var docs []*yaml.Node
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(doc.Content) > 0 {
+			docs = append(docs, doc.Content[0])
+		}
+	}
+	return docs, nil
+}
+
+type yamlDiffer struct {
+	opts *options
+	// visited holds the pairs of aliased nodes being compared, so that
+	// recursive aliases terminate.
+	visited map[[2]*yaml.Node]bool
+}
+
+// diff compares n1 and n2, either of which may be nil if missing. via names
+// the alias through which they were reached, if any.
+func (y *yamlDiffer) diff(path string, n1, n2 *yaml.Node, via string) *Difference {
+	n1, via1 := resolveAlias(n1)
+	n2, via2 := resolveAlias(n2)
+	if via1 != "" || via2 != "" {
+		key := [2]*yaml.Node{n1, n2}
+		if y.visited[key] {
+			return nil
+		}
+		y.visited[key] = true
+		defer delete(y.visited, key)
+		if via1 != "" {
+			via = via1
+		} else {
+			via = via2
+		}
+	}
+	switch {
+	case n1 == nil:
+		return yamlDifference(path, Inserted, "Only in right: "+yamlText(n2), n1, n2, via)
+	case n2 == nil:
+		return yamlDifference(path, Deleted, "Only in left: "+yamlText(n1), n1, n2, via)
+	}
+	t1, t2 := yamlType(n1), yamlType(n2)
+	if t1 != t2 {
+		return yamlDifference(path, Modified, fmt.Sprintf("Type changed from %s to %s: %s != %s", t1, t2, yamlText(n1), yamlText(n2)), n1, n2, via)
+	}
+	switch n1.Kind {
+	case yaml.MappingNode:
+		return y.diffMappings(path, n1, n2, via)
+	case yaml.SequenceNode:
+		return y.diffSequences(path, n1, n2, via)
+	}
+	if yamlScalarsEqual(n1, n2) {
+		return nil
+	}
+	return yamlDifference(path, Modified, fmt.Sprintf("%s != %s", yamlText(n1), yamlText(n2)), n1, n2, via)
+}
+
+func (y *yamlDiffer) diffMappings(path string, n1, n2 *yaml.Node, via string) *Difference {
+	keys1, vals1, vias1 := mappingEntries(n1)
+	keys2, vals2, vias2 := mappingEntries(n2)
+	keys := keys1
+	for _, k := range keys2 {
+		if _, ok := vals1[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	node := &Difference{Path: path}
+	for _, k := range keys {
+		p := path + jsonKeyStep(k)
+		if y.opts.excluded(p) {
+			continue
+		}
+		v := via
+		if merged := vias1[k] + vias2[k]; merged != "" {
+			v = merged
+		}
+		if d := y.diff(p, vals1[k], vals2[k], v); d != nil {
+			node.Children = append(node.Children, d)
+		}
+	}
+	if len(node.Children) == 0 {
+		return nil
+	}
+	return node
+}
+
+func (y *yamlDiffer) diffSequences(path string, n1, n2 *yaml.Node, via string) *Difference {
+	node := &Difference{Path: path}
+	for i := 0; i < len(n1.Content) || i < len(n2.Content); i++ {
+		p := path + "[" + strconv.Itoa(i) + "]"
+		if y.opts.excluded(p) {
+			continue
+		}
+		var c1, c2 *yaml.Node
+		if i < len(n1.Content) {
+			c1 = n1.Content[i]
+		}
+		if i < len(n2.Content) {
+			c2 = n2.Content[i]
+		}
+		if d := y.diff(p, c1, c2, via); d != nil {
+			node.Children = append(node.Children, d)
+		}
+	}
+	if len(node.Children) == 0 {
+		return nil
+	}
+	return node
+}
+
+// resolveAlias follows n to the node it's an alias of, if it is one,
+// returning the alias as written.
+func resolveAlias(n *yaml.Node) (*yaml.Node, string) {
+	via := ""
+	for n != nil && n.Kind == yaml.AliasNode {
+		via = "*" + n.Value
+		n = n.Alias
+	}
+	return n, via
+}
+
+// mappingEntries returns the keys of a mapping in order, and its values by
+// key, with merge keys expanded. Keys given explicitly take precedence over
+// merged ones, and earlier merged mappings over later ones. For merged keys,
+// vias records the alias they were merged from.
+func mappingEntries(n *yaml.Node) (keys []string, vals map[string]*yaml.Node, vias map[string]string) {
+	vals = make(map[string]*yaml.Node)
+	vias = make(map[string]string)
+	var merges []*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if k.Tag == "!!merge" {
+			merges = append(merges, v)
+			continue
+		}
+		if _, ok := vals[k.Value]; !ok {
+			keys = append(keys, k.Value)
+		}
+		vals[k.Value] = v
+	}
+	for _, m := range merges {
+		m, mvia := resolveAlias(m)
+		srcs := []*yaml.Node{m}
+		if m.Kind == yaml.SequenceNode {
+			srcs = m.Content
+		}
+		for _, src := range srcs {
+			src, via := resolveAlias(src)
+			if via == "" {
+				via = mvia
+			}
+			if src.Kind != yaml.MappingNode {
+				continue
+			}
+			mkeys, mvals, _ := mappingEntries(src)
+			for _, k := range mkeys {
+				if _, ok := vals[k]; !ok {
+					keys = append(keys, k)
+					vals[k] = mvals[k]
+					vias[k] = via
+				}
+			}
+		}
+	}
+	return keys, vals, vias
+}
+
+func yamlType(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "!!map"
+	case yaml.SequenceNode:
+		return "!!seq"
+	}
+	switch tag := n.ShortTag(); tag {
+	case "!!int", "!!float":
+		return "number"
+	default:
+		return tag
+	}
+}
+
+// yamlScalarsEqual compares two scalars of the same type by value, so that
+// 0x10 and 16, or 1.0 and 1, are equal.
+func yamlScalarsEqual(n1, n2 *yaml.Node) bool {
+	v1, v2 := yamlValue(n1), yamlValue(n2)
+	if r1, ok := yamlNumber(v1); ok {
+		r2, ok := yamlNumber(v2)
+		return ok && r1.Cmp(r2) == 0
+	}
+	if t1, ok := v1.(time.Time); ok {
+		t2, ok := v2.(time.Time)
+		return ok && t1.Equal(t2)
+	}
+	return reflect.DeepEqual(v1, v2)
+}
+
+func yamlNumber(v interface{}) (*big.Rat, bool) {
+	switch x := v.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(x)), true
+	case int64:
+		return new(big.Rat).SetInt64(x), true
+	case uint64:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(x)), true
+	case float64:
+		if r := new(big.Rat); r.SetFloat64(x) != nil {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// yamlValue decodes n, or returns nil if it can't be decoded.
+func yamlValue(n *yaml.Node) interface{} {
+	if n == nil {
+		return nil
+	}
+	var v interface{}
+	if err := n.Decode(&v); err != nil {
+		return nil
+	}
+	return v
+}
+
+// yamlText formats n compactly, in JSON syntax for collections.
+func yamlText(n *yaml.Node) string {
+	if n.Kind == yaml.ScalarNode {
+		if n.ShortTag() == "!!str" {
+			return strconv.Quote(n.Value)
+		}
+		return n.Value
+	}
+	return jsonText(yamlValue(n))
+}
+
+func yamlDifference(path string, kind ChangeKind, msg string, n1, n2 *yaml.Node, via string) *Difference {
+	if via != "" {
+		msg += " (via alias " + via + ")"
+	}
+	d := &Difference{Path: path, Kind: kind, Message: msg}
+	if n1 != nil {
+		d.Left, d.LeftText = yamlValue(n1), yamlText(n1)
+	}
+	if n2 != nil {
+		d.Right, d.RightText = yamlValue(n2), yamlText(n2)
+	}
+	return d
+}
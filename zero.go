@@ -0,0 +1,84 @@
+package debugtools
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// IsDeepZero reports whether v is the zero value of its type, and if it
+// isn't, returns the paths, written as in Result.Path, of the parts that
+// aren't zero. Structs and arrays are looked into, so that every nonzero
+// field is listed, including unexported ones; anything else, such as a
+// non-nil pointer, slice or map, is a single nonzero part, however zero
+// what it refers to. If v is itself a pointer, the value it points to is
+// checked. Paths skipped by IgnorePaths or OnlyPaths aren't checked, for
+// fields that are meant to survive a reset, such as an ID:
+//
+//	if zero, paths := debugtools.IsDeepZero(pool.get(), debugtools.IgnorePaths("id")); !zero {
+//		panic("pooled buffer not reset: " + strings.Join(paths, ", "))
+//	}
+func IsDeepZero(v interface{}, opts ...Option) (bool, []string) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	var paths []string
+	if rv.IsValid() {
+		nonZeroPaths(rv, "", newOptions(opts), &paths)
+	}
+	return len(paths) == 0, paths
+}
+
+// nonZeroPaths appends the paths of the nonzero parts of v, at path, to
+// paths.
+func nonZeroPaths(v reflect.Value, path string, o *options, paths *[]string) {
+	if path != "" && o.excluded(path) {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			nonZeroPaths(v.Field(i), path+"."+v.Type().Field(i).Name, o, paths)
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			nonZeroPaths(v.Index(i), path+"["+strconv.Itoa(i)+"]", o, paths)
+		}
+	default:
+		if !v.IsZero() {
+			*paths = append(*paths, path)
+		}
+	}
+}
+
+// AssertDeepZero checks that v is zero, as IsDeepZero decides with opts,
+// and reports whether it is. If it isn't, t fails with the paths of its
+// nonzero parts and a dump of v:
+//
+//	buf.Reset()
+//	debugtools.AssertDeepZero(t, buf)
+func AssertDeepZero(t testing.TB, v interface{}, opts ...Option) bool {
+	t.Helper()
+	zero, paths := IsDeepZero(v, opts...)
+	if zero {
+		return true
+	}
+	var sb strings.Builder
+	sb.WriteString("value is not zero; nonzero parts:")
+	for _, p := range paths {
+		sb.WriteString("\n\t" + displayPath(p))
+	}
+	fmt.Fprintf(&sb, "\nvalue: %s", Sdump(v, DumpCompact(), DumpUnexported(), DumpColor(ColorNever)))
+	sb.WriteString(artifactsNote(t, opts, func() []artifact {
+		want := reflect.Zero(reflect.TypeOf(v))
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+			want = reflect.New(rv.Type().Elem())
+		}
+		return valueArtifacts(want.Interface(), v, opts)
+	}))
+	fail(t, opts, sb.String())
+	return false
+}